@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	contextPathEnvVar         = "SPACECTX_CONTEXT_PATH"
+	spaceliftWorkspaceEnvVar  = "TF_VAR_spacelift_workspace_root"
+	spaceliftDefaultWorkspace = "/mnt/workspace"
+)
+
+// contextOutput is a single output value to write to a context file.
+type contextOutput struct {
+	name      string
+	sensitive bool
+	value     json.RawMessage
+}
+
+// contextLoader reads context files from the search path, keeping values in
+// memory so each context is only read once.
+type contextLoader struct {
+	folders  []string
+	contexts map[string]cty.Value
+}
+
+func newContextLoader(folders []string) *contextLoader {
+	return &contextLoader{
+		folders:  contextSearchPath(folders),
+		contexts: map[string]cty.Value{},
+	}
+}
+
+// load returns the values of context name.
+func (cl *contextLoader) load(name string) (cty.Value, error) {
+	if values, ok := cl.contexts[name]; ok {
+		return values, nil
+	}
+
+	values, err := readContextFiles(cl.folders, name)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	cl.contexts[name] = values
+
+	return values, nil
+}
+
+// evalContext returns an evaluation context with the contexts referenced by
+// attrs available as the context variable.
+func (cl *contextLoader) evalContext(attrs hcl.Attributes) (*hcl.EvalContext, error) {
+	names, err := findContextsInUse(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]cty.Value{}
+
+	for _, name := range names {
+		values, err := cl.load(name)
+		if err != nil {
+			return nil, err
+		}
+
+		variables[name] = values
+	}
+
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"context": cty.ObjectVal(variables),
+		},
+	}, nil
+}
+
+// contextSearchPath returns the folders to search for context files. Folders set
+// by flag are used as is, otherwise they are read from SPACECTX_CONTEXT_PATH or
+// the project configuration file. If none is set it searches current folder and
+// the spacelift workspace root, which is where spacelift mounts context files.
+func contextSearchPath(folders []string) []string {
+	if len(folders) == 0 {
+		folders = filepath.SplitList(os.Getenv(contextPathEnvVar))
+	}
+
+	if len(folders) == 0 {
+		folders = projectConfig.Process.SourceFolders
+	}
+
+	if len(folders) == 0 {
+		workspace := os.Getenv(spaceliftWorkspaceEnvVar)
+		if workspace == "" {
+			workspace = spaceliftDefaultWorkspace
+		}
+
+		folders = []string{".", workspace}
+	}
+
+	result := []string{}
+	seen := map[string]bool{}
+
+	for _, folder := range folders {
+		if folder == "" {
+			continue
+		}
+
+		key := folder
+		if abs, err := filepath.Abs(folder); err == nil {
+			key = abs
+		}
+
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		result = append(result, folder)
+	}
+
+	log.Debugf("Context search path: %v", result)
+
+	return result
+}
+
+// checkContextName returns an error if name can not be used in the name of a
+// context file, as it would refer to a file outside the context folders.
+func checkContextName(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return errors.Errorf("Invalid context name %q, must not contain path separators or ..", name)
+	}
+
+	return nil
+}
+
+// findContextFile returns the first existing file named fn in folders. Names
+// with path separators are never found.
+func findContextFile(folders []string, fn string) (string, bool) {
+	if filepath.Base(fn) != fn {
+		return "", false
+	}
+
+	for _, folder := range folders {
+		path := filepath.Join(folder, fn)
+
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+func unmarshalFile(fn string) (cty.Value, error) {
+	fn = filepath.Clean(fn)
+
+	_, err := os.Lstat(fn)
+	if err != nil {
+		return cty.NilVal, errors.Wrapf(err, "Failed to stat %q", fn)
+	}
+
+	src, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return cty.NilVal, errors.Wrapf(err, "Failed to read file %v", fn)
+	}
+
+	ctype, err := ctyjson.ImpliedType(src)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	return ctyjson.Unmarshal(src, ctype)
+}
+
+func readContextFiles(folders []string, name string) (cty.Value, error) {
+	files := []string{
+		fmt.Sprintf(contextFileName, name),
+		fmt.Sprintf(contextSecretsFileName, name),
+	}
+
+	variables := map[string]cty.Value{}
+
+	for _, fn := range files {
+		values, err := readContextFile(folders, fn)
+		if err != nil {
+			return cty.NilVal, err
+		}
+
+		for k, v := range values {
+			variables[k] = v
+		}
+	}
+
+	return cty.ObjectVal(variables), nil
+}
+
+// readContextFile returns the values in the first context file named fn found
+// in folders, or nil if not found. A file that can not be read is an error, so a
+// corrupt file is never mistaken for a context without values.
+func readContextFile(folders []string, fn string) (map[string]cty.Value, error) {
+	file, ok := findContextFile(folders, fn)
+	if !ok {
+		log.Debugf("Context file %s not found, skipping", fn)
+		return nil, nil
+	}
+
+	vars, err := unmarshalFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read context file %s", file)
+	}
+
+	if !vars.Type().IsObjectType() {
+		return nil, errors.Errorf("Failed to read context file %s: content is not an object", file)
+	}
+
+	log.Debugf("Read context file %s", file)
+
+	return vars.AsValueMap(), nil
+}
+
+// stringValue returns value as a plain string. Primitive values are converted to
+// their string representation and complex values are json encoded.
+func stringValue(value cty.Value) (string, error) {
+	if value.IsNull() {
+		return "", errors.Errorf("Value is null")
+	}
+
+	if !value.IsWhollyKnown() {
+		return "", errors.Errorf("Value is not known")
+	}
+
+	if value.Type().IsPrimitiveType() {
+		str, err := convert.Convert(value, cty.String)
+		if err != nil {
+			return "", err
+		}
+
+		return str.AsString(), nil
+	}
+
+	bytes, err := ctyjson.Marshal(value, value.Type())
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+// flattenValue calls fn for each value nested in value, together with the path
+// of keys and indexes leading to it. Null values and empty collections are
+// passed to fn as is.
+func flattenValue(path []string, value cty.Value, fn func([]string, cty.Value) error) error {
+	ty := value.Type()
+	nested := ty.IsObjectType() || ty.IsMapType() || ty.IsListType() || ty.IsTupleType()
+
+	if !nested || value.IsNull() || !value.IsKnown() || value.LengthInt() == 0 {
+		return fn(path, value)
+	}
+
+	for it := value.ElementIterator(); it.Next(); {
+		k, v := it.Element()
+
+		key, err := stringValue(k)
+		if err != nil {
+			return err
+		}
+
+		if err := flattenValue(append(append([]string{}, path...), key), v, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeContextFiles writes outputs to the context files for name in folder, split
+// into a public file and a secrets file the same way generate does. The secrets
+// file is only written if there are sensitive outputs.
+func writeContextFiles(folder string, name string, outputs []*contextOutput) error {
+	if err := checkContextName(name); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Failed to create folder %s", folder)
+	}
+
+	files := []struct {
+		fileName  string
+		sensitive bool
+		perm      os.FileMode
+	}{
+		{contextFileName, false, os.ModePerm},
+		{contextSecretsFileName, true, 0600},
+	}
+
+	for _, f := range files {
+		content, count, err := encodeContextFile(outputs, f.sensitive)
+		if err != nil {
+			return err
+		}
+
+		if f.sensitive && count == 0 {
+			continue
+		}
+
+		fn := filepath.Join(folder, fmt.Sprintf(f.fileName, name))
+
+		if err := ioutil.WriteFile(fn, content, f.perm); err != nil {
+			return errors.Wrapf(err, "Failed to write file %s", fn)
+		}
+
+		log.Printf("Wrote %d values to %s", count, fn)
+	}
+
+	return nil
+}
+
+// encodeContextFile returns the content of the public or secrets context file
+// for outputs, and the number of values in it.
+func encodeContextFile(outputs []*contextOutput, sensitive bool) ([]byte, int, error) {
+	values := map[string]json.RawMessage{}
+
+	for _, output := range outputs {
+		if output.sensitive == sensitive {
+			values[output.name] = output.value
+		}
+	}
+
+	content, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return append(content, '\n'), len(values), nil
+}
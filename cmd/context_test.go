@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+func TestReadContextFile(t *testing.T) {
+	folder := t.TempDir()
+
+	files := map[string]string{
+		"ctx-valid.json":   `{"vnet_id": "vnet-1"}`,
+		"ctx-corrupt.json": `{"vnet_id": `,
+		"ctx-list.json":    `["vnet-1"]`,
+	}
+
+	for fn, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(folder, fn), []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		fn      string
+		want    int
+		wantErr bool
+	}{
+		{"ctx-valid.json", 1, false},
+		{"ctx-missing.json", 0, false},
+		{"ctx-corrupt.json", 0, true},
+		{"ctx-list.json", 0, true},
+	}
+
+	for _, tt := range tests {
+		values, err := readContextFile([]string{folder}, tt.fn)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readContextFile(%s) error = %v, wantErr %v", tt.fn, err, tt.wantErr)
+		}
+
+		if len(values) != tt.want {
+			t.Errorf("readContextFile(%s) = %v, want %d values", tt.fn, values, tt.want)
+		}
+	}
+}
+
+func TestContextLoaderFailsOnCorruptFile(t *testing.T) {
+	folder := t.TempDir()
+
+	if err := ioutil.WriteFile(filepath.Join(folder, "ctx-net-secrets.json"), []byte(`{`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte("vnet_id = context.net.vnet_id\n"), "test.tfvars")
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	attrs, _ := file.Body.JustAttributes()
+
+	if _, err := newContextLoader([]string{folder}).evalContext(attrs); err == nil {
+		t.Errorf("evalContext() should fail on corrupt context file")
+	}
+
+	if _, err := newContextLoader([]string{folder}).evalContext(hcl.Attributes{}); err != nil {
+		t.Errorf("evalContext() without references error = %v", err)
+	}
+}
+
+// writeContextFolder writes files with content to a temporary folder, and
+// returns the folder.
+func writeContextFolder(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	folder := t.TempDir()
+
+	for fn, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(folder, fn), []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return folder
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type envCmd struct {
+	keys            []string
+	format          string
+	prefix          string
+	separator       string
+	keyCase         string
+	flatten         bool
+	withContextName bool
+	contextFolders  []string
+}
+
+const (
+	envFormatSh     = "sh"
+	envFormatDotenv = "dotenv"
+	envFormatGithub = "github"
+
+	envCaseUpper = "upper"
+	envCaseLower = "lower"
+	envCaseKeep  = "keep"
+
+	githubDelimiter = "SPACECTX_EOF"
+)
+
+var (
+	invalidEnvKeyChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+	envLong = templates.LongDesc(`Print values from context files as environment variables. Useful for
+		steps that does not run terraform, like scripts using kubectl or helm. By default all keys in
+		the context are printed, use --key to select specific keys.
+
+		Nested values are json encoded, unless --flatten is set in which case each nested value gets
+		its own variable named by joining the keys with --separator.`)
+
+	envExample = templates.Examples(`
+		# Export all values from context network-dev in current shell
+		eval "$(spacectx env network-dev)"
+
+		# Write selected values as dotenv file
+		spacectx env network-dev -k vnet_id -k subnet_ids --format dotenv --flatten > .env
+
+		# Add values to environment of later steps in a GitHub Actions job
+		spacectx env network-dev --format github --prefix NET_ >> "$GITHUB_ENV"
+	`)
+)
+
+func newEnvCmd() *cobra.Command {
+	ec := &envCmd{}
+
+	envCmd := &cobra.Command{
+		Use:                   "env CONTEXT...",
+		Short:                 "Print context values as environment variables",
+		Long:                  envLong,
+		Example:               envExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
+		Args:                  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ec.init(args); err != nil {
+				return err
+			}
+
+			return ec.run(cmd.OutOrStdout(), args)
+		},
+	}
+
+	f := envCmd.Flags()
+	f.StringArrayVarP(&ec.keys, "key", "k", nil, "key in context to print, defaults to all keys")
+	f.StringVarP(&ec.format, "format", "f", envFormatSh, "output format, sh, dotenv or github")
+	f.StringVar(&ec.prefix, "prefix", "", "prefix to add to all variable names")
+	f.StringVar(&ec.separator, "separator", "_", "separator used when joining names")
+	f.StringVar(&ec.keyCase, "case", envCaseUpper, "case of variable names, upper, lower or keep")
+	f.BoolVar(&ec.flatten, "flatten", false, "flatten nested values into separate variables")
+	f.BoolVar(&ec.withContextName, "with-context-name", false, "prefix variable names with name of context")
+	f.StringSliceVarP(&ec.contextFolders, "source-folder", "s", nil, "source folders to read context files from, searched in order. defaults to $SPACECTX_CONTEXT_PATH or current folder and spacelift workspace root")
+
+	return envCmd
+}
+
+func (ec *envCmd) init(args []string) error {
+	switch ec.format {
+	case envFormatSh, envFormatDotenv, envFormatGithub:
+	default:
+		return errors.Errorf("Unsupported format %q, must be %s, %s or %s", ec.format, envFormatSh, envFormatDotenv, envFormatGithub)
+	}
+
+	switch ec.keyCase {
+	case envCaseUpper, envCaseLower, envCaseKeep:
+	default:
+		return errors.Errorf("Unsupported case %q, must be %s, %s or %s", ec.keyCase, envCaseUpper, envCaseLower, envCaseKeep)
+	}
+
+	return nil
+}
+
+func (ec *envCmd) run(out io.Writer, args []string) error {
+	loader := newContextLoader(ec.contextFolders)
+	variables := map[string]string{}
+
+	for _, name := range args {
+		context, err := loader.load(name)
+		if err != nil {
+			return err
+		}
+
+		values := context.AsValueMap()
+		if len(values) == 0 {
+			return errors.Errorf("No values found for context %s", name)
+		}
+
+		keys := ec.keys
+		if len(keys) == 0 {
+			for key := range values {
+				keys = append(keys, key)
+			}
+		}
+
+		for _, key := range keys {
+			value, ok := values[key]
+			if !ok {
+				return errors.Errorf("Key %s not found in context %s", key, name)
+			}
+
+			path := []string{key}
+			if ec.withContextName {
+				path = []string{name, key}
+			}
+
+			if err := ec.addVariables(variables, path, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := fmt.Fprintln(out, ec.formatVariable(name, variables[name])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ec *envCmd) addVariables(variables map[string]string, path []string, value cty.Value) error {
+	if !ec.flatten {
+		return ec.addVariable(variables, path, value)
+	}
+
+	return flattenValue(path, value, func(path []string, value cty.Value) error {
+		return ec.addVariable(variables, path, value)
+	})
+}
+
+func (ec *envCmd) addVariable(variables map[string]string, path []string, value cty.Value) error {
+	name := ec.variableName(path)
+
+	if _, exists := variables[name]; exists {
+		return errors.Errorf("Variable %s is defined more than once", name)
+	}
+
+	str := ""
+	if !value.IsNull() {
+		var err error
+
+		str, err = stringValue(value)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to convert value for %s", name)
+		}
+	}
+
+	variables[name] = str
+
+	return nil
+}
+
+func (ec *envCmd) variableName(path []string) string {
+	name := ec.prefix + strings.Join(path, ec.separator)
+	name = invalidEnvKeyChars.ReplaceAllString(name, "_")
+
+	switch ec.keyCase {
+	case envCaseUpper:
+		name = strings.ToUpper(name)
+	case envCaseLower:
+		name = strings.ToLower(name)
+	}
+
+	return name
+}
+
+func (ec *envCmd) formatVariable(name string, value string) string {
+	switch ec.format {
+	case envFormatDotenv:
+		replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+		return fmt.Sprintf(`%s="%s"`, name, replacer.Replace(value))
+	case envFormatGithub:
+		if !strings.ContainsAny(value, "\r\n") {
+			return fmt.Sprintf("%s=%s", name, value)
+		}
+
+		delimiter := githubDelimiter
+		for i := 0; strings.Contains(value, delimiter); i++ {
+			delimiter = fmt.Sprintf("%s_%d", githubDelimiter, i)
+		}
+
+		return fmt.Sprintf("%s<<%s%s%s%s%s", name, delimiter, "\n", value, "\n", delimiter)
+	default:
+		return fmt.Sprintf("export %s='%s'", name, strings.ReplaceAll(value, "'", `'\''`))
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	folder := writeContextFolder(t, map[string]string{
+		"ctx-net.json":         `{"vnet_id": "vnet-1", "subnets": {"app": "10.0.1.0/24"}, "note": "it's\nmultiline"}`,
+		"ctx-net-secrets.json": `{"password": "secret"}`,
+	})
+
+	tests := []struct {
+		name string
+		cmd  envCmd
+		want string
+	}{
+		{
+			name: "sh",
+			cmd:  envCmd{keys: []string{"vnet_id", "subnets", "note"}},
+			want: `export NOTE='it'\''s
+multiline'
+export SUBNETS='{"app":"10.0.1.0/24"}'
+export VNET_ID='vnet-1'
+`,
+		},
+		{
+			name: "dotenv flattened",
+			cmd:  envCmd{format: envFormatDotenv, flatten: true, keys: []string{"subnets", "note"}},
+			want: `NOTE="it's\nmultiline"
+SUBNETS_APP="10.0.1.0/24"
+`,
+		},
+		{
+			name: "github",
+			cmd:  envCmd{format: envFormatGithub, prefix: "net_", keyCase: envCaseLower, keys: []string{"vnet_id", "note"}},
+			want: `net_note<<SPACECTX_EOF
+it's
+multiline
+SPACECTX_EOF
+net_vnet_id=vnet-1
+`,
+		},
+		{
+			name: "secrets with context name",
+			cmd:  envCmd{withContextName: true, keys: []string{"password"}},
+			want: "export NET_PASSWORD='secret'\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec := tt.cmd
+			ec.contextFolders = []string{folder}
+			ec.separator = "_"
+
+			if ec.format == "" {
+				ec.format = envFormatSh
+			}
+			if ec.keyCase == "" {
+				ec.keyCase = envCaseUpper
+			}
+
+			if err := ec.init([]string{"net"}); err != nil {
+				t.Fatalf("init() error = %v", err)
+			}
+
+			out := &bytes.Buffer{}
+			if err := ec.run(out, []string{"net"}); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			if out.String() != tt.want {
+				t.Errorf("run() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvErrors(t *testing.T) {
+	folder := writeContextFolder(t, map[string]string{
+		"ctx-net.json":     `{"vnet_id": "vnet-1", "VNET_ID": "other"}`,
+		"ctx-corrupt.json": `{`,
+	})
+
+	tests := []struct {
+		name    string
+		cmd     envCmd
+		context string
+		wantErr string
+	}{
+		{"missing context", envCmd{}, "missing", "No values found for context missing"},
+		{"missing key", envCmd{keys: []string{"subnets"}}, "net", "Key subnets not found in context net"},
+		{"duplicate variable", envCmd{}, "net", "Variable VNET_ID is defined more than once"},
+		{"corrupt context", envCmd{}, "corrupt", "Failed to read context file"},
+		{"invalid format", envCmd{format: "yaml"}, "net", "Unsupported format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec := tt.cmd
+			ec.contextFolders = []string{folder}
+			ec.separator = "_"
+			ec.keyCase = envCaseUpper
+
+			if ec.format == "" {
+				ec.format = envFormatSh
+			}
+
+			err := ec.init([]string{tt.context})
+			if err == nil {
+				err = ec.run(&bytes.Buffer{}, []string{tt.context})
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// ExitError is returned when an executed command exits with a non zero code,
+// which spacectx should exit with as well.
+type ExitError struct {
+	Command string
+	Code    int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%s exited with code %d", e.Command, e.Code)
+}
+
+type execCmd struct {
+	mappings       []string
+	contextFolders []string
+
+	names []string
+	attrs hcl.Attributes
+}
+
+const (
+	terraformVariablePrefix = "TF_VAR_"
+)
+
+var (
+	execLong = templates.LongDesc(`Execute a command with values from context exported as terraform input
+		variables. Each mapping sets environment variable TF_VAR_<name> to the value of a context
+		reference. Complex values are json encoded. No processed files are written to disk.`)
+
+	execExample = templates.Examples(`
+		# Run terraform plan with var.vnet_id set from context
+		spacectx exec --map vnet_id=context.net.vnet_id -- terraform plan
+
+		# Map several values
+		spacectx exec -m vnet_id=context.net.vnet_id -m subnets=context.net.subnet_ids -- terraform apply
+	`)
+)
+
+func newExecCmd() *cobra.Command {
+	ec := &execCmd{}
+
+	execCmd := &cobra.Command{
+		Use:                   "exec -- COMMAND [ARGS...]",
+		Short:                 "Execute command with context values as terraform variables",
+		Long:                  execLong,
+		Example:               execExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
+		Args:                  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ec.init(args); err != nil {
+				return err
+			}
+
+			return ec.run(args)
+		},
+	}
+
+	f := execCmd.Flags()
+	f.SetInterspersed(false)
+	f.StringArrayVarP(&ec.mappings, "map", "m", nil, "variable to set from context, in format name=expression")
+	f.StringSliceVarP(&ec.contextFolders, "source-folder", "s", nil, "source folders to read context files from, searched in order. defaults to $SPACECTX_CONTEXT_PATH or current folder and spacelift workspace root")
+
+	return execCmd
+}
+
+func (ec *execCmd) init(args []string) error {
+	names, attrs, err := parseMappings(ec.mappings)
+	if err != nil {
+		return err
+	}
+
+	ec.names = names
+	ec.attrs = attrs
+
+	return nil
+}
+
+func (ec *execCmd) run(args []string) error {
+	evalContext, err := newContextLoader(ec.contextFolders).evalContext(ec.attrs)
+	if err != nil {
+		return err
+	}
+
+	env := os.Environ()
+
+	for _, name := range ec.names {
+		attr := ec.attrs[name]
+
+		value, diags := attr.Expr.Value(evalContext)
+		if err := checkDiags(diags); err != nil {
+			return err
+		}
+
+		str, err := stringValue(value)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to convert value for %s", attr.Name)
+		}
+
+		log.Debugf("Setting %s%s", terraformVariablePrefix, attr.Name)
+		env = append(env, fmt.Sprintf("%s%s=%s", terraformVariablePrefix, attr.Name, str))
+	}
+
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = env
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	// Interrupts are delivered to the child as well, so let it decide how to exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	if err := child.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return &ExitError{Command: args[0], Code: exitErr.ExitCode()}
+		}
+
+		return errors.Wrapf(err, "Failed to execute %s", args[0])
+	}
+
+	return nil
+}
+
+// parseMappings parses mappings written as name=expression into attributes, so
+// they can be evaluated the same way as attributes in a tfvars file. Names are
+// returned in the order given.
+func parseMappings(mappings []string) ([]string, hcl.Attributes, error) {
+	names := []string{}
+	attrs := hcl.Attributes{}
+
+	for _, mapping := range mappings {
+		parts := strings.SplitN(mapping, "=", 2)
+		if len(parts) != 2 || !hclsyntax.ValidIdentifier(parts[0]) {
+			return nil, nil, errors.Errorf("Invalid mapping %q, expected format name=expression", mapping)
+		}
+
+		name := parts[0]
+
+		if _, exists := attrs[name]; exists {
+			return nil, nil, errors.Errorf("Variable %s is mapped more than once", name)
+		}
+
+		expr, diags := hclsyntax.ParseExpression([]byte(parts[1]), fmt.Sprintf("<map %s>", name), hcl.Pos{Line: 1, Column: 1, Byte: 0})
+		if err := checkDiags(diags); err != nil {
+			return nil, nil, err
+		}
+
+		names = append(names, name)
+		attrs[name] = &hcl.Attribute{
+			Name:  name,
+			Expr:  expr,
+			Range: expr.Range(),
+		}
+	}
+
+	return names, attrs, nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExec(t *testing.T) {
+	folder := t.TempDir()
+	out := filepath.Join(t.TempDir(), "out")
+
+	if err := ioutil.WriteFile(filepath.Join(folder, "ctx-net.json"), []byte(`{"vnet_id": "vnet-1", "subnets": ["a", "b"]}`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	ec := &execCmd{
+		mappings:       []string{"vnet_id=context.net.vnet_id", "subnets=context.net.subnets"},
+		contextFolders: []string{folder},
+	}
+
+	args := []string{"sh", "-c", `printf '%s %s' "$TF_VAR_vnet_id" "$TF_VAR_subnets" > "$0"`, out}
+
+	if err := ec.init(args); err != nil {
+		t.Fatalf("init() error = %v", err)
+	}
+
+	if err := ec.run(args); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	content, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `vnet-1 ["a","b"]`; string(content) != want {
+		t.Errorf("Environment = %s, want %s", content, want)
+	}
+}
+
+func TestExecExitCode(t *testing.T) {
+	ec := &execCmd{contextFolders: []string{t.TempDir()}}
+
+	err := ec.run([]string{"sh", "-c", "exit 3"})
+
+	exitErr, ok := err.(*ExitError)
+	if !ok {
+		t.Fatalf("run() error = %v, want ExitError", err)
+	}
+
+	if exitErr.Code != 3 {
+		t.Errorf("Exit code = %d, want 3", exitErr.Code)
+	}
+
+	if err := ec.run([]string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Errorf("run() of missing command should fail")
+	} else if _, ok := err.(*ExitError); ok {
+		t.Errorf("run() of missing command should not return ExitError")
+	}
+}
+
+func TestParseMappings(t *testing.T) {
+	tests := []struct {
+		mappings []string
+		wantErr  bool
+	}{
+		{[]string{"a=context.net.a", "b=\"literal\""}, false},
+		{[]string{"a"}, true},
+		{[]string{"1a=context.net.a"}, true},
+		{[]string{"a=context.net.a", "a=context.net.b"}, true},
+		{[]string{"a=context.net."}, true},
+	}
+
+	for _, tt := range tests {
+		names, _, err := parseMappings(tt.mappings)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMappings(%v) error = %v, wantErr %v", tt.mappings, err, tt.wantErr)
+		}
+
+		if err == nil && len(names) != len(tt.mappings) {
+			t.Errorf("parseMappings(%v) names = %v", tt.mappings, names)
+		}
+	}
+}
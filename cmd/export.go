@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type exportK8sCmd struct {
+	name           string
+	secretName     string
+	namespace      string
+	labels         map[string]string
+	ignoreSecrets  bool
+	outputFile     string
+	contextFolders []string
+}
+
+var (
+	invalidK8sNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+	exportLong = templates.LongDesc(`Export context values to formats used by other tools.`)
+
+	exportK8sLong = templates.LongDesc(`Export context as kubernetes manifests. Values from the context file
+		are written to a ConfigMap and values from the secrets file to a Secret, with one key per output.
+		Complex values are json encoded.`)
+
+	exportK8sExample = templates.Examples(`
+		# Export context network-dev and apply to cluster
+		spacectx export k8s network-dev -n platform | kubectl apply -f -
+
+		# Export with custom name and labels, skipping secrets
+		spacectx export k8s network-dev --name network --label team=platform --ignore-secrets -o network.yaml
+	`)
+)
+
+func newExportCmd() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export context values to other formats",
+		Long:  exportLong,
+	}
+
+	exportCmd.AddCommand(newExportK8sCmd())
+
+	return exportCmd
+}
+
+func newExportK8sCmd() *cobra.Command {
+	ec := &exportK8sCmd{}
+
+	exportK8sCmd := &cobra.Command{
+		Use:                   "k8s CONTEXT",
+		Short:                 "Export context as kubernetes ConfigMap and Secret",
+		Long:                  exportK8sLong,
+		Example:               exportK8sExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ec.init(args); err != nil {
+				return err
+			}
+
+			return ec.run(args)
+		},
+	}
+
+	f := exportK8sCmd.Flags()
+	f.StringVar(&ec.name, "name", "", "name of ConfigMap, defaults to name of context")
+	f.StringVar(&ec.secretName, "secret-name", "", "name of Secret, defaults to same as ConfigMap")
+	f.StringVarP(&ec.namespace, "namespace", "n", "", "namespace of resources")
+	f.StringToStringVarP(&ec.labels, "label", "l", nil, "labels to add to resources, in format key=value")
+	f.BoolVar(&ec.ignoreSecrets, "ignore-secrets", false, "do not export secrets")
+	f.StringVarP(&ec.outputFile, "output", "o", "", "file to write manifests to. if not set it writes to stdout")
+	f.StringSliceVarP(&ec.contextFolders, "source-folder", "s", nil, "source folders to read context files from, searched in order. defaults to $SPACECTX_CONTEXT_PATH or current folder and spacelift workspace root")
+
+	return exportK8sCmd
+}
+
+func (ec *exportK8sCmd) init(args []string) error {
+	if ec.name == "" {
+		ec.name = strings.Trim(invalidK8sNameChars.ReplaceAllString(strings.ToLower(args[0]), "-"), "-")
+	}
+
+	if ec.secretName == "" {
+		ec.secretName = ec.name
+	}
+
+	if ec.name == "" {
+		return errors.Errorf("Name of resources is not set")
+	}
+
+	if ec.labels == nil {
+		ec.labels = map[string]string{}
+	}
+
+	if _, ok := ec.labels["app.kubernetes.io/managed-by"]; !ok {
+		ec.labels["app.kubernetes.io/managed-by"] = "spacectx"
+	}
+
+	return nil
+}
+
+func (ec *exportK8sCmd) run(args []string) error {
+	name := args[0]
+	folders := contextSearchPath(ec.contextFolders)
+
+	manifests := [][]byte{}
+
+	values, err := readContextFile(folders, fmt.Sprintf(contextFileName, name))
+	if err != nil {
+		return err
+	}
+
+	if values != nil {
+		manifest, err := ec.buildManifest("ConfigMap", ec.name, values, false)
+		if err != nil {
+			return err
+		}
+
+		manifests = append(manifests, manifest)
+	}
+
+	if !ec.ignoreSecrets {
+		values, err := readContextFile(folders, fmt.Sprintf(contextSecretsFileName, name))
+		if err != nil {
+			return err
+		}
+
+		if values != nil {
+			manifest, err := ec.buildManifest("Secret", ec.secretName, values, true)
+			if err != nil {
+				return err
+			}
+
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	if len(manifests) == 0 {
+		return errors.Errorf("No context files found for context %s", name)
+	}
+
+	result := bytes.Join(manifests, []byte("---\n"))
+
+	if ec.outputFile == "" {
+		fmt.Print(string(result))
+		return nil
+	}
+
+	log.Debugf("Writing manifests to %s", ec.outputFile)
+
+	return ioutil.WriteFile(ec.outputFile, result, os.ModePerm)
+}
+
+// buildManifest writes a ConfigMap or Secret as yaml. Strings are written as json
+// strings, which are valid yaml scalars, so no yaml encoder is needed.
+func (ec *exportK8sCmd) buildManifest(kind string, name string, values map[string]cty.Value, secret bool) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "apiVersion: v1\n")
+	fmt.Fprintf(buf, "kind: %s\n", kind)
+	fmt.Fprintf(buf, "metadata:\n")
+	fmt.Fprintf(buf, "  name: %s\n", quoteYAML(name))
+
+	if ec.namespace != "" {
+		fmt.Fprintf(buf, "  namespace: %s\n", quoteYAML(ec.namespace))
+	}
+
+	if len(ec.labels) > 0 {
+		fmt.Fprintf(buf, "  labels:\n")
+
+		for _, key := range sortedKeys(ec.labels) {
+			fmt.Fprintf(buf, "    %s: %s\n", quoteYAML(key), quoteYAML(ec.labels[key]))
+		}
+	}
+
+	if secret {
+		fmt.Fprintf(buf, "type: Opaque\n")
+	}
+
+	fmt.Fprintf(buf, "data:\n")
+
+	data := map[string]string{}
+
+	for key, value := range values {
+		str := ""
+		if !value.IsNull() {
+			var err error
+
+			str, err = stringValue(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to convert value for %s", key)
+			}
+		}
+
+		if secret {
+			str = base64.StdEncoding.EncodeToString([]byte(str))
+		}
+
+		data[key] = str
+	}
+
+	for _, key := range sortedKeys(data) {
+		fmt.Fprintf(buf, "  %s: %s\n", quoteYAML(key), quoteYAML(data[key]))
+	}
+
+	return buf.Bytes(), nil
+}
+
+func quoteYAML(s string) string {
+	bytes, _ := json.Marshal(s)
+	return string(bytes)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type externalCmd struct {
+	contextFolders []string
+}
+
+const (
+	externalQueryContext   = "context"
+	externalQuerySeparator = "separator"
+	externalQuerySecrets   = "secrets"
+)
+
+var (
+	externalLong = templates.LongDesc(`Read context values using the protocol of the terraform external data
+		source. The query is read as json from stdin and the values of the context are written as a flat
+		json object of strings to stdout. Nested values are flattened by joining keys with a separator.
+
+		Supported query arguments are context (required), separator (defaults to ".") and secrets
+		("false" to exclude values from the secrets file).`)
+
+	externalExample = templates.Examples(`
+		# Use as external data source in terraform
+		data "external" "ctx" {
+		  program = ["spacectx", "external"]
+		  query   = { context = "network-dev" }
+		}
+
+		# Test from command line
+		echo '{"context": "network-dev"}' | spacectx external
+	`)
+)
+
+func newExternalCmd() *cobra.Command {
+	ec := &externalCmd{}
+
+	externalCmd := &cobra.Command{
+		Use:                   "external",
+		Short:                 "Read context values as terraform external data source",
+		Long:                  externalLong,
+		Example:               externalExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
+		Args:                  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ec.run(cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	f := externalCmd.Flags()
+	f.StringSliceVarP(&ec.contextFolders, "source-folder", "s", nil, "source folders to read context files from, searched in order. defaults to $SPACECTX_CONTEXT_PATH or current folder and spacelift workspace root")
+
+	return externalCmd
+}
+
+func (ec *externalCmd) run(in io.Reader, out io.Writer) error {
+	query := map[string]string{}
+
+	if err := json.NewDecoder(in).Decode(&query); err != nil {
+		return errors.Wrap(err, "Failed to read query from stdin")
+	}
+
+	name := query[externalQueryContext]
+	if name == "" {
+		return errors.Errorf("Query argument %s is not set", externalQueryContext)
+	}
+
+	separator, ok := query[externalQuerySeparator]
+	if !ok {
+		separator = "."
+	}
+
+	folders := contextSearchPath(ec.contextFolders)
+	values, err := readContextFile(folders, fmt.Sprintf(contextFileName, name))
+	if err != nil {
+		return err
+	}
+
+	if query[externalQuerySecrets] != "false" {
+		secrets, err := readContextFile(folders, fmt.Sprintf(contextSecretsFileName, name))
+		if err != nil {
+			return err
+		}
+
+		if secrets != nil && values == nil {
+			values = map[string]cty.Value{}
+		}
+
+		for k, v := range secrets {
+			values[k] = v
+		}
+	}
+
+	if values == nil {
+		return errors.Errorf("No context files found for context %s", name)
+	}
+
+	result := map[string]string{}
+
+	for key, value := range values {
+		err := flattenValue([]string{key}, value, func(path []string, value cty.Value) error {
+			str := ""
+			if !value.IsNull() {
+				var err error
+
+				str, err = stringValue(value)
+				if err != nil {
+					return err
+				}
+			}
+
+			result[strings.Join(path, separator)] = str
+
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Failed to convert value for %s", key)
+		}
+	}
+
+	return json.NewEncoder(out).Encode(result)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExternal(t *testing.T) {
+	folder := writeContextFolder(t, map[string]string{
+		"ctx-net.json":         `{"vnet_id": "vnet-1", "subnets": {"app": ["a", "b"]}, "count": 2, "empty": null}`,
+		"ctx-net-secrets.json": `{"password": "secret"}`,
+		"ctx-corrupt.json":     `{`,
+	})
+
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "default separator",
+			query: `{"context": "net"}`,
+			want:  `{"count":"2","empty":"","password":"secret","subnets.app.0":"a","subnets.app.1":"b","vnet_id":"vnet-1"}`,
+		},
+		{
+			name:  "custom separator without secrets",
+			query: `{"context": "net", "separator": "_", "secrets": "false"}`,
+			want:  `{"count":"2","empty":"","subnets_app_0":"a","subnets_app_1":"b","vnet_id":"vnet-1"}`,
+		},
+		{name: "invalid query", query: `{"context": 1}`, wantErr: "Failed to read query from stdin"},
+		{name: "missing context argument", query: `{}`, wantErr: "Query argument context is not set"},
+		{name: "context not found", query: `{"context": "missing"}`, wantErr: "No context files found for context missing"},
+		{name: "corrupt context", query: `{"context": "corrupt"}`, wantErr: "Failed to read context file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+
+			err := (&externalCmd{contextFolders: []string{folder}}).run(strings.NewReader(tt.query), out)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("run() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
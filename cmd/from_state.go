@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type fromStateCmd struct {
+	contextName string
+	outputDir   string
+}
+
+// stateDocument holds the outputs of both formats supported: raw state files
+// have outputs at top level, while terraform show -json has them under values
+// for state and planned_values for plans.
+type stateDocument struct {
+	Version       int                         `json:"version"`
+	FormatVersion string                      `json:"format_version"`
+	Outputs       map[string]*terraformOutput `json:"outputs"`
+	Values        *struct {
+		Outputs map[string]*terraformOutput `json:"outputs"`
+	} `json:"values"`
+	PlannedValues *struct {
+		Outputs map[string]*terraformOutput `json:"outputs"`
+	} `json:"planned_values"`
+}
+
+var (
+	fromStateLong = templates.LongDesc(`Create context files from the outputs recorded in a terraform state
+		file, or in a state or plan document from terraform show -json. Outputs marked as sensitive are
+		written to the secrets file, the same way as the context generated by spacectx generate.`)
+
+	fromStateExample = templates.Examples(`
+		# Create context network-dev from local state file
+		spacectx from-state terraform.tfstate --name network-dev
+
+		# Create context from a plan
+		terraform show -json plan.out > plan.json
+		spacectx from-state plan.json --name network-dev -d .contexts
+	`)
+)
+
+func newFromStateCmd() *cobra.Command {
+	fc := &fromStateCmd{}
+
+	fromStateCmd := &cobra.Command{
+		Use:                   "from-state FILE",
+		Short:                 "Create context files from terraform state",
+		Long:                  fromStateLong,
+		Example:               fromStateExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := fc.init(args); err != nil {
+				return err
+			}
+
+			return fc.run(args)
+		},
+	}
+
+	f := fromStateCmd.Flags()
+	f.StringVarP(&fc.contextName, "name", "n", "", "name of context to create")
+	f.StringVarP(&fc.outputDir, "output-dir", "d", ".", "folder to write context files to")
+
+	return fromStateCmd
+}
+
+func (fc *fromStateCmd) init(args []string) error {
+	if fc.contextName == "" {
+		return contextNameIsRequired
+	}
+
+	return nil
+}
+
+func (fc *fromStateCmd) run(args []string) error {
+	fn := args[0]
+
+	src, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read file %v", fn)
+	}
+
+	outputs, err := parseStateOutputs(src)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read outputs from %s", fn)
+	}
+
+	if len(outputs) == 0 {
+		log.Printf("No outputs found, skipping.")
+		return nil
+	}
+
+	return writeContextFiles(fc.outputDir, fc.contextName, outputs)
+}
+
+// parseStateOutputs returns the outputs in a state file or a terraform show -json
+// document. Outputs without a known value are skipped.
+func parseStateOutputs(src []byte) ([]*contextOutput, error) {
+	doc := &stateDocument{}
+
+	if err := json.Unmarshal(src, doc); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]*terraformOutput
+
+	switch {
+	case doc.FormatVersion != "" && doc.PlannedValues != nil:
+		log.Debugf("Reading outputs from plan")
+		raw = doc.PlannedValues.Outputs
+	case doc.FormatVersion != "" && doc.Values != nil:
+		log.Debugf("Reading outputs from terraform show state")
+		raw = doc.Values.Outputs
+	case doc.FormatVersion != "":
+		return nil, errors.Errorf("Document does not contain any values")
+	case doc.Version >= 4:
+		log.Debugf("Reading outputs from state file version %d", doc.Version)
+		raw = doc.Outputs
+	default:
+		return nil, errors.Errorf("Unsupported state format, only state version 4 or terraform show -json is supported")
+	}
+
+	return contextOutputs(raw), nil
+}
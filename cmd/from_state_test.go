@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromState(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "state file",
+			state: `{"version": 4, "outputs": {
+				"vnet_id": {"value": "vnet-1", "type": "string"},
+				"password": {"value": "secret", "type": "string", "sensitive": true}
+			}}`,
+			want: map[string]string{
+				"ctx-network-dev.json":         `{"vnet_id":"vnet-1"}`,
+				"ctx-network-dev-secrets.json": `{"password":"secret"}`,
+			},
+		},
+		{
+			name: "show state",
+			state: `{"format_version": "1.0", "values": {"outputs": {
+				"subnets": {"value": ["a", "b"], "sensitive": false}
+			}}}`,
+			want: map[string]string{"ctx-network-dev.json": `{"subnets":["a","b"]}`},
+		},
+		{
+			name: "show plan",
+			state: `{"format_version": "1.0", "planned_values": {"outputs": {
+				"vnet_id": {"value": "vnet-2", "sensitive": false},
+				"pending": {"sensitive": false}
+			}}}`,
+			want: map[string]string{"ctx-network-dev.json": `{"vnet_id":"vnet-2"}`},
+		},
+		{name: "no outputs", state: `{"version": 4, "outputs": {}}`, want: map[string]string{}},
+		{name: "old state version", state: `{"version": 3}`, wantErr: "Unsupported state format"},
+		{name: "show without values", state: `{"format_version": "1.0"}`, wantErr: "Document does not contain any values"},
+		{name: "invalid json", state: `{`, wantErr: "Failed to read outputs from"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := &fromStateCmd{contextName: "network-dev", outputDir: t.TempDir()}
+
+			err := fc.run([]string{writeOutputs(t, tt.state)})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			got := contextFolderContent(t, fc.outputDir)
+			if len(got) != len(tt.want) {
+				t.Errorf("Files written = %v, want %v", got, tt.want)
+			}
+
+			for fn, want := range tt.want {
+				if got[fn] != want {
+					t.Errorf("Content of %s = %s, want %s", fn, got[fn], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFromStateErrors(t *testing.T) {
+	if err := (&fromStateCmd{}).init([]string{"terraform.tfstate"}); err != contextNameIsRequired {
+		t.Errorf("init() without name error = %v, want %v", err, contextNameIsRequired)
+	}
+
+	fc := &fromStateCmd{contextName: "network", outputDir: t.TempDir()}
+	if err := fc.run([]string{filepath.Join(t.TempDir(), "missing.tfstate")}); err == nil || !strings.Contains(err.Error(), "Failed to read file") {
+		t.Errorf("run() with missing file error = %v, want Failed to read file", err)
+	}
+}
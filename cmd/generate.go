@@ -2,16 +2,23 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/zclconf/go-cty/cty"
 
+	"github.com/2ttech/spacectx/internal/config"
 	"github.com/2ttech/spacectx/internal/helpers"
+	"github.com/2ttech/spacectx/internal/spacelift"
 	"github.com/2ttech/spacectx/internal/templates"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/pkg/errors"
@@ -19,9 +26,30 @@ import (
 )
 
 type generateCmd struct {
-	files       string
-	contextName string
-	outputFile  string
+	files        string
+	contextName  string
+	stackID      string
+	outputFile   string
+	applyViaAPI  bool
+	apiEndpoint  string
+	outputsFile  string
+	backend      string
+	consumers    []string
+	inputPrefix  string
+	organization string
+	beforeInit   []string
+	processHook  bool
+	autoattach   bool
+	labels       []string
+	include      []string
+	exclude      []string
+	secrets      string
+	adopt        string
+	previousName string
+
+	providerSource  string
+	providerVersion string
+	mergeProvider   bool
 }
 
 type outputDefinitions struct {
@@ -31,10 +59,51 @@ type outputDefinitions struct {
 }
 
 var (
-	contextNameIsNotSet = errors.Errorf("context name is not set")
+	contextNameIsNotSet = errors.Errorf("context name is not set, and no stack found in $TF_VAR_spacelift_stack_id or %s", spacelift.ConfigFile)
+	stackIsNotSet       = errors.Errorf("stack is not set with --name, and no stack found in $TF_VAR_spacelift_stack_id or %s", spacelift.ConfigFile)
+
+	backendContext         = "context"
+	backendStackDependency = "stack-dependency"
+	backendTFEVariableSet  = "tfe-variable-set"
+
+	processHookCommand = "spacectx process ."
 
 	generateLong = templates.LongDesc(`Generate spacelift context resources based on the output resources
-				in tf files. By default it searches all tf files in current folder.`)
+				in tf files. By default it searches all tf files in current folder.
+
+				With --apply-via-api no resources are generated. Instead the outputs are read after apply, and
+				the context and its mounted files are created or updated directly through the spacelift API.
+				This does not require the stack to be administrative, but needs credentials for the API.
+
+				With --backend stack-dependency no context is used. Instead a stack dependency is generated
+				for each consumer stack, with references passing every output as a variable to the consumer.
+				The name is then used as id of the producing stack.
+
+				Hooks can be added to the generated context with --before-init, or --process-hook to run
+				spacectx process on the *.workspace.tfvars files of every stack the context is attached to.
+				Consumers then only need to attach the context.
+
+				If not set, the name defaults to the id of the stack, read from TF_VAR_spacelift_stack_id or
+				from the stack in .spacelift/config.yml having the folder as project root. With --autoattach
+				the context is attached to all stacks labelled with the name of the context.
+
+				Outputs can be filtered by name with --include and --exclude patterns. Sensitive outputs are
+				written as secrets, which can be changed with --secrets to all or none. Defaults for these
+				and other settings are read from .spacectx.hcl, searched for in current folder and its parents.
+
+				If the provider used by the resources is not declared in required_providers, in tf or tf.json
+				files, override files or local modules, it is added to spacectx_override.tf. With
+				--merge-provider it is instead added to the first terraform block of the module.
+
+				A context created by hand can be adopted with --adopt, which adds an import block for it
+				(requires terraform 1.5 or later), or updates it through the API with --apply-via-api. Renames
+				are detected from the name in the previously generated file, or --previous-name. The resource
+				addresses do not depend on the name, so a rename does not replace the context, but consumers
+				referencing the previous name are reported as they must be updated. If the previously
+				generated file used other resource addresses, moved blocks are added for them.
+
+				With --backend tfe-variable-set the outputs are instead written as variables in a Terraform
+				Cloud variable set with the given name, for stacks running on Terraform Cloud.`)
 
 	generateExample = templates.Examples(`
 		# Generate in current folder
@@ -45,6 +114,24 @@ var (
 
 		# Generate based on wildcard
 		spacectx generate *.tf
+
+		# Create or update context through the spacelift API after apply
+		spacectx generate --apply-via-api
+
+		# Generate context processing *.workspace.tfvars in stacks it is attached to
+		spacectx generate --process-hook
+
+		# Generate context attached to all stacks labelled network-dev
+		spacectx generate --name network-dev --autoattach
+
+		# Adopt context created by hand with id network-dev
+		spacectx generate --name network-dev --adopt network-dev
+
+		# Pass outputs to stacks app-dev and db-dev with stack dependencies
+		spacectx generate --backend stack-dependency --consumer app-dev --consumer db-dev
+
+		# Generate Terraform Cloud variable set network-dev
+		spacectx generate --backend tfe-variable-set --name network-dev --tfe-organization my-org
 	`)
 )
 
@@ -59,6 +146,7 @@ func newGenerateCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		SilenceUsage:          true,
 		SilenceErrors:         true,
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
 		Args:                  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := gc.init(args); err != nil {
@@ -72,6 +160,25 @@ func newGenerateCmd() *cobra.Command {
 	f := generateCmd.Flags()
 	f.StringVarP(&gc.contextName, "name", "n", "", "name of context to create, defaults to same as stack name")
 	f.StringVarP(&gc.outputFile, "output", "o", "spacelift_context.tf", "name of output file to create, defaults to spacelift_context.tf")
+	f.BoolVar(&gc.applyViaAPI, "apply-via-api", false, "create or update context through spacelift API instead of generating resources")
+	f.StringVar(&gc.apiEndpoint, "api-endpoint", "", "spacelift API endpoint, defaults to $SPACELIFT_API_KEY_ENDPOINT")
+	f.StringVar(&gc.outputsFile, "outputs-file", "", "file with output from terraform output -json, used with --apply-via-api. if not set it runs terraform output")
+	f.StringVar(&gc.backend, "backend", backendContext, "how outputs are passed to consumers, either context, stack-dependency or tfe-variable-set")
+	f.StringArrayVar(&gc.consumers, "consumer", []string{}, "id of stack consuming outputs, used with --backend stack-dependency. can be repeated")
+	f.StringVar(&gc.inputPrefix, "input-prefix", "TF_VAR_", "prefix of input name for output references, used with --backend stack-dependency")
+	f.StringArrayVar(&gc.beforeInit, "before-init", []string{}, "command to run before init in stacks the context is attached to. can be repeated")
+	f.BoolVar(&gc.processHook, "process-hook", false, fmt.Sprintf("add before init hook running %q to context", processHookCommand))
+	f.BoolVar(&gc.autoattach, "autoattach", projectConfig.Context.Autoattach, "attach context to all stacks labelled with the name of the context")
+	f.StringArrayVar(&gc.labels, "label", projectConfig.Context.Labels, "label to add to generated context. can be repeated")
+	f.StringArrayVar(&gc.include, "include", projectConfig.Outputs.Include, "pattern of output names to include, defaults to all. can be repeated")
+	f.StringArrayVar(&gc.exclude, "exclude", projectConfig.Outputs.Exclude, "pattern of output names to exclude. can be repeated")
+	f.StringVar(&gc.providerSource, "provider-source", defaultProviderSource(), "source of spacelift provider added to required_providers")
+	f.StringVar(&gc.providerVersion, "provider-version", defaultProviderVersion(), "version constraint of spacelift provider added to required_providers")
+	f.BoolVar(&gc.mergeProvider, "merge-provider", projectConfig.Provider.Merge, "add provider requirement to existing terraform block instead of writing "+spaceliftOverrideFile)
+	f.StringVar(&gc.secrets, "secrets", projectConfig.Outputs.Secrets, "outputs to write as secrets, either sensitive, all or none")
+	f.StringVar(&gc.adopt, "adopt", "", "id of existing context to import instead of creating a new one")
+	f.StringVar(&gc.previousName, "previous-name", projectConfig.Context.PreviousName, "previous name of context, defaults to name in existing output file")
+	f.StringVar(&gc.organization, "tfe-organization", "", "Terraform Cloud organization of variable set, used with --backend tfe-variable-set. defaults to $TFE_ORGANIZATION")
 
 	return generateCmd
 }
@@ -83,22 +190,128 @@ func (gc *generateCmd) init(args []string) error {
 		gc.files = args[0]
 	}
 
-	if gc.contextName == "" {
-		gc.contextName = os.Getenv("TF_VAR_spacelift_stack_id")
+	if gc.backend == backendStackDependency {
+		stackID, err := gc.defaultStackID()
+		if err != nil {
+			return err
+		}
 
-		if gc.contextName == "" {
-			return contextNameIsNotSet
+		gc.stackID = stackID
+	} else if gc.contextName == "" {
+		name, err := defaultContextName(gc.files)
+		if err != nil {
+			return err
 		}
 
-		log.Debugf("Using context name %s", gc.contextName)
+		gc.contextName = name
 	}
 
-	return nil
+	if gc.applyViaAPI && gc.backend != backendContext {
+		return errors.Errorf("Backend %s can not be used with --apply-via-api", gc.backend)
+	}
+
+	if gc.processHook {
+		gc.beforeInit = append(gc.beforeInit, processHookCommand)
+	}
+
+	if len(gc.beforeInit) > 0 && (gc.backend != backendContext || gc.applyViaAPI) {
+		return errors.Errorf("Hooks can only be added to contexts generated as resources")
+	}
+
+	if gc.adopt != "" && gc.backend != backendContext {
+		return errors.Errorf("Only contexts can be adopted, not supported with backend %s", gc.backend)
+	}
+
+	if (gc.autoattach || len(gc.labels) > 0) && (gc.backend != backendContext || gc.applyViaAPI) {
+		log.Warnf("Labels are only added to contexts generated as resources, ignoring")
+	}
+
+	switch gc.secrets {
+	case config.SecretsSensitive, config.SecretsAll, config.SecretsNone:
+	default:
+		return errors.Errorf("Invalid secrets %s, must be %s, %s or %s", gc.secrets, config.SecretsSensitive, config.SecretsAll, config.SecretsNone)
+	}
+
+	if gc.organization == "" {
+		gc.organization = os.Getenv("TFE_ORGANIZATION")
+	}
+
+	_, err := gc.sink()
+
+	return err
+}
+
+// defaultStackID returns the id of the stack other stacks depend on with backend
+// stack-dependency. It is set by --name, or is the id of the current stack. The
+// context name template is not used, as it only applies to contexts.
+func (gc *generateCmd) defaultStackID() (string, error) {
+	if gc.contextName != "" {
+		return gc.contextName, nil
+	}
+
+	stack, _, err := currentStack(gc.files)
+	if err != nil {
+		return "", err
+	}
+
+	if stack == nil || stack.ID == "" {
+		return "", stackIsNotSet
+	}
+
+	return stack.ID, nil
+}
+
+// sink returns the context sink for selected backend.
+func (gc *generateCmd) sink() (contextSink, error) {
+	switch gc.backend {
+	case backendContext:
+		_, previous := previousContext(gc.outputFile)
+
+		sink := &spaceliftContextSink{
+			contextName: gc.contextName,
+			beforeInit:  gc.beforeInit,
+			labels:      gc.labels,
+			adopt:       gc.adopt,
+			requirement: spaceliftProvider(gc.providerSource, gc.providerVersion),
+			previous:    previous,
+		}
+		if gc.autoattach {
+			sink.labels = append(sink.labels, autoattachLabelPrefix+gc.contextName)
+		}
+
+		return sink, nil
+	case backendStackDependency:
+		if len(gc.consumers) == 0 {
+			return nil, errors.Errorf("At least one consumer is required with backend %s", gc.backend)
+		}
+
+		return &spaceliftStackDependencySink{
+			stackID:     gc.stackID,
+			consumers:   gc.consumers,
+			inputPrefix: gc.inputPrefix,
+			requirement: spaceliftProvider(gc.providerSource, gc.providerVersion),
+		}, nil
+	case backendTFEVariableSet:
+		if gc.organization == "" {
+			return nil, errors.Errorf("Organization is required with backend %s", gc.backend)
+		}
+
+		return &tfeVariableSetSink{name: gc.contextName, organization: gc.organization}, nil
+	}
+
+	return nil, errors.Errorf("Unknown backend %s, must be %s, %s or %s", gc.backend, backendContext, backendStackDependency, backendTFEVariableSet)
 }
 
 func (gc *generateCmd) run(args []string) error {
 
-	outputs := []*outputDefinitions{}
+	if gc.applyViaAPI {
+		return gc.runViaAPI()
+	}
+
+	sink, err := gc.sink()
+	if err != nil {
+		return err
+	}
 
 	files, err := helpers.ReadFiles(gc.files)
 
@@ -106,25 +319,85 @@ func (gc *generateCmd) run(args []string) error {
 		return err
 	}
 
-	providerReqExists := false
+	outputs := []*outputDefinitions{}
 
-	for _, file := range files {
-		body := file.Body()
+	for _, output := range findOutputs(files) {
+		if selectOutput(output.name, &output.sensitive, gc.include, gc.exclude, gc.secrets) {
+			outputs = append(outputs, output)
+		}
+	}
 
-		if gc.checkProviderRequirementsExists(body) {
-			providerReqExists = true
+	if len(outputs) == 0 {
+		log.Printf("No outputs defined, skipping.")
+		return nil
+	}
+
+	if gc.backend == backendContext {
+		gc.checkRename()
+	}
+
+	file := sink.build(outputs)
+
+	if gc.autoattach {
+		gc.logAutoattachedStacks()
+	}
+
+	dir := gc.files
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	if err := ensureProviderRequirement(dir, sink.provider(), gc.mergeProvider, spaceliftOverrideFile); err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(gc.outputFile, file.Bytes(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Finished creating spacelift context file")
+
+	return nil
+}
+
+// runViaAPI reads outputs after apply and writes them to the context through the
+// spacelift API, creating the context if it does not exist.
+func (gc *generateCmd) runViaAPI() error {
+	client, err := newSpaceliftClient(gc.apiEndpoint)
+	if err != nil {
+		return err
+	}
+
+	var src []byte
+
+	if gc.outputsFile != "" {
+		src, err = ioutil.ReadFile(gc.outputsFile)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read file %v", gc.outputsFile)
+		}
+	} else {
+		dir := gc.files
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			dir = filepath.Dir(dir)
 		}
 
-		for _, block := range body.Blocks() {
-			blockBody := block.Body()
+		src, err = terraformOutputJSON(dir)
+		if err != nil {
+			return err
+		}
+	}
 
-			if block.Type() == "output" {
-				outputs = append(outputs, &outputDefinitions{
-					name:      block.Labels()[0],
-					sensitive: checkSensitiveAttr(blockBody),
-					expr:      blockBody.GetAttribute("value").Expr(),
-				})
-			}
+	all, err := parseTerraformOutputs(src)
+	if err != nil {
+		return err
+	}
+
+	outputs := []*contextOutput{}
+
+	for _, output := range all {
+		if selectOutput(output.name, &output.sensitive, gc.include, gc.exclude, gc.secrets) {
+			outputs = append(outputs, output)
 		}
 	}
 
@@ -133,114 +406,250 @@ func (gc *generateCmd) run(args []string) error {
 		return nil
 	}
 
-	file := gc.buildContext(outputs)
+	id := spacelift.Slug(gc.contextName)
+	if gc.adopt != "" {
+		id = gc.adopt
+	}
 
-	if !providerReqExists {
-		providerFile := gc.buildProviderRequirements()
+	ctx, err := client.Context(id)
+	if err != nil {
+		return err
+	}
 
-		err = ioutil.WriteFile(spaceliftOverrideFile, providerFile.Bytes(), os.ModePerm)
+	if ctx == nil && gc.adopt != "" {
+		return errors.Errorf("Context %s to adopt not found", gc.adopt)
+	}
+
+	if ctx != nil && ctx.Name != gc.contextName {
+		log.Warnf("Context %s is named %s, not %s. Context files are named after %s", ctx.ID, ctx.Name, gc.contextName, gc.contextName)
+	}
+
+	if ctx == nil {
+		log.Printf("Creating context %s", gc.contextName)
+
+		ctx, err = client.CreateContext(gc.contextName, "Auto generated context by spacectx")
 		if err != nil {
 			return err
 		}
 	}
 
-	err = ioutil.WriteFile(gc.outputFile, file.Bytes(), os.ModePerm)
-	if err != nil {
-		return err
+	files := []struct {
+		fileName  string
+		sensitive bool
+	}{
+		{contextFileName, false},
+		{contextSecretsFileName, true},
 	}
 
-	log.Println("Finished creating spacelift context file")
+	for _, f := range files {
+		fn := fmt.Sprintf(f.fileName, gc.contextName)
+
+		content, count, err := encodeContextFile(outputs, f.sensitive)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			if hasConfig(ctx, fn) {
+				log.Printf("Removing %s from context %s", fn, ctx.ID)
+
+				if err := client.DeleteConfig(ctx.ID, fn); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		log.Printf("Writing %d values to %s in context %s", count, fn, ctx.ID)
+
+		err = client.AddConfig(ctx.ID, &spacelift.ConfigInput{
+			ID:        fn,
+			Type:      spacelift.ConfigTypeFileMount,
+			Value:     base64.StdEncoding.EncodeToString(content),
+			WriteOnly: f.sensitive,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Println("Finished updating spacelift context")
 
 	return nil
 }
 
-func (gc *generateCmd) checkProviderRequirementsExists(body *hclwrite.Body) bool {
-	exists := false
-
-	for _, block := range body.Blocks() {
-		if block.Type() == "terraform" {
-			for _, tBlock := range block.Body().Blocks() {
-				if tBlock.Type() == "required_providers" {
-					for name := range tBlock.Body().Attributes() {
-						if name == "spacelift" {
-							exists = true
-						}
-					}
-				}
+// checkRename warns if the context has been renamed since it was last generated,
+// as consumers reference contexts by name.
+func (gc *generateCmd) checkRename() {
+	previous := gc.previousName
+	if previous == "" {
+		previous, _ = previousContext(gc.outputFile)
+	}
+
+	if previous == "" || previous == gc.contextName {
+		return
+	}
+
+	log.Warnf("Context renamed from %s to %s. The resource address is unchanged, but consumers referencing context.%s must be updated", previous, gc.contextName, previous)
+}
+
+// previousContext returns the name of the context in a previously generated file
+// fn, and the addresses of its resources. The addresses are nil if fn does not
+// exist or has no context.
+func previousContext(fn string) (string, *contextAddresses) {
+	file, diags := hclparse.NewParser().ParseHCLFile(fn)
+	if diags.HasErrors() {
+		return "", nil
+	}
+
+	content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "resource", LabelNames: []string{"type", "name"}}},
+	})
+
+	name := ""
+	var addresses *contextAddresses
+
+	for _, block := range content.Blocks {
+		attrs, _ := block.Body.JustAttributes()
+
+		switch block.Labels[0] {
+		case "spacelift_context":
+			if addresses != nil {
+				continue
+			}
+
+			addresses = &contextAddresses{context: block.Labels[1]}
+			name = staticString(attrs["name"])
+		case "spacelift_mounted_file":
+			if addresses == nil {
+				continue
+			}
+
+			// The secrets file is the only file that is write only
+			if writeOnly := staticBool(attrs["write_only"]); writeOnly {
+				addresses.secretsFile = block.Labels[1]
+			} else {
+				addresses.file = block.Labels[1]
 			}
 		}
 	}
 
-	return exists
+	return name, addresses
 }
 
-func (gc *generateCmd) buildProviderRequirements() *hclwrite.File {
-	file := hclwrite.NewEmptyFile()
+// staticString returns the value of attr if it is a string literal, or empty.
+func staticString(attr *hcl.Attribute) string {
+	if attr == nil {
+		return ""
+	}
 
-	block := file.Body().AppendNewBlock("terraform", []string{})
-	providerBlock := block.Body().AppendNewBlock("required_providers", []string{})
-	providerBlock.Body().SetAttributeValue("spacelift", cty.ObjectVal(map[string]cty.Value{
-		"source":  cty.StringVal("spacelift-io/spacelift"),
-		"version": cty.StringVal(fmt.Sprintf("~> %s", spaceliftProviderVersion)),
-	}))
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || value.Type() != cty.String {
+		return ""
+	}
 
-	return file
+	return value.AsString()
 }
 
-func (gc *generateCmd) buildContext(outputs []*outputDefinitions) *hclwrite.File {
-	file := hclwrite.NewEmptyFile()
-	body := file.Body()
+// staticBool returns the value of attr if it is a bool literal, or false.
+func staticBool(attr *hcl.Attribute) bool {
+	if attr == nil {
+		return false
+	}
 
-	contextBlock := body.AppendNewBlock("resource", []string{"spacelift_context", "outputs"})
-	contextBlock.Body().SetAttributeValue("name", cty.StringVal(gc.contextName))
-	contextBlock.Body().SetAttributeValue("description", cty.StringVal("Auto generated context by spacectx"))
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || value.Type() != cty.Bool {
+		return false
+	}
 
-	localsBlock := body.AppendNewBlock("locals", []string{})
+	return value.True()
+}
 
-	for _, output := range outputs {
-		localsBlock.Body().SetAttributeRaw(fmt.Sprintf("out_%s", output.name), output.expr.BuildTokens(nil))
+// logAutoattachedStacks reports which stacks in the spacelift config file the
+// context will be attached to.
+func (gc *generateCmd) logAutoattachedStacks() {
+	_, config, err := currentStack(gc.files)
+	if err != nil || config == nil {
+		return
 	}
 
-	if checkIfAny(outputs, func(o *outputDefinitions) bool { return !o.sensitive }) {
-		gc.appendFileBlock(body, outputs, false, contextFileName, "out_sctx_content")
+	stacks := config.StacksWithLabel(gc.contextName)
+	if len(stacks) == 0 {
+		log.Warnf("No stacks in %s are labelled %s, context will not be attached", spacelift.ConfigFile, gc.contextName)
+		return
 	}
-	if checkIfAny(outputs, func(o *outputDefinitions) bool { return o.sensitive }) {
-		gc.appendFileBlock(body, outputs, true, contextSecretsFileName, "out_sctx_content_secrets")
+
+	log.Printf("Context will be attached to stacks %s", strings.Join(stacks, ", "))
+}
+
+// selectOutput returns true if output name matches the include and exclude
+// patterns, and updates sensitive according to the secrets strategy.
+func selectOutput(name string, sensitive *bool, include []string, exclude []string, secrets string) bool {
+	if !config.MatchOutput(name, include, exclude) {
+		log.Debugf("Skipping output %s, not matching filters", name)
+		return false
 	}
 
-	return file
+	switch secrets {
+	case config.SecretsAll:
+		*sensitive = true
+	case config.SecretsNone:
+		*sensitive = false
+	}
+
+	return true
 }
 
-func (gc *generateCmd) appendFileBlock(body *hclwrite.Body, outputs []*outputDefinitions, sensitive bool, fileName string, localAttributeName string) {
-	fileBlock := body.AppendNewBlock("resource", []string{"spacelift_mounted_file", localAttributeName})
-	fileBlock.Body().SetAttributeTraversal("context_id", hcl.Traversal{
-		hcl.TraverseRoot{
-			Name: "spacelift_context",
-		},
-		hcl.TraverseAttr{
-			Name: "outputs",
-		},
-		hcl.TraverseAttr{
-			Name: "id",
-		},
-	})
-	fileBlock.Body().SetAttributeValue("relative_path", cty.StringVal(fmt.Sprintf(fileName, gc.contextName)))
-	fileBlock.Body().SetAttributeValue("write_only", cty.BoolVal(sensitive))
-	fileBlock.Body().SetAttributeTraversal("content", hcl.Traversal{
-		hcl.TraverseRoot{
-			Name: "local",
-		},
-		hcl.TraverseAttr{
-			Name: localAttributeName,
-		},
-	})
+func hasConfig(ctx *spacelift.Context, id string) bool {
+	for _, element := range ctx.Config {
+		if element.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findOutputs returns the output blocks defined in files.
+func findOutputs(files []*hclwrite.File) []*outputDefinitions {
+	outputs := []*outputDefinitions{}
+
+	for _, file := range files {
+		for _, block := range file.Body().Blocks() {
+			blockBody := block.Body()
+
+			if block.Type() == "output" {
+				outputs = append(outputs, &outputDefinitions{
+					name:      block.Labels()[0],
+					sensitive: checkSensitiveAttr(blockBody),
+					expr:      blockBody.GetAttribute("value").Expr(),
+				})
+			}
+		}
+	}
 
-	localsBlock := body.FirstMatchingBlock("locals", []string{})
+	return outputs
+}
+
+// resourceName returns id usable as name of a resource, replacing all characters
+// not allowed in identifiers.
+func resourceName(id string) string {
+	name := []rune{}
+
+	for _, r := range id {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			name = append(name, r)
+		} else {
+			name = append(name, '_')
+		}
+	}
 
-	unencodedName := fmt.Sprintf("%s_raw", localAttributeName)
+	if len(name) == 0 || unicode.IsDigit(name[0]) {
+		name = append([]rune{'_'}, name...)
+	}
 
-	localsBlock.Body().SetAttributeRaw(unencodedName, localsContent(outputs, sensitive).BuildTokens(nil))
-	localsBlock.Body().SetAttributeRaw(localAttributeName, localsContentEncoded(unencodedName).BuildTokens(nil))
+	return string(name)
 }
 
 func checkSensitiveAttr(body *hclwrite.Body) bool {
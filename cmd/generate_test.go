@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/2ttech/spacectx/internal/config"
+	"github.com/2ttech/spacectx/internal/spacelift"
+	"github.com/2ttech/spacectx/internal/spacelift/fake"
+)
+
+const terraformOutputs = `{
+  "vnet_id": {"sensitive": false, "type": "string", "value": "vnet-1"},
+  "subnets": {"sensitive": false, "type": ["list", "string"], "value": ["a", "b"]},
+  "password": {"sensitive": true, "type": "string", "value": "secret"}
+}`
+
+// setenv sets environment variable key for the duration of the test.
+func setenv(t *testing.T, key string, value string) {
+	t.Helper()
+
+	previous, ok := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+}
+
+// setSpaceliftCredentials sets the credentials used by newSpaceliftClient, with
+// either token or API key set.
+func setSpaceliftCredentials(t *testing.T, token string, keyID string, keySecret string) {
+	setenv(t, spaceliftEndpointEnvVar, "")
+	setenv(t, spaceliftTokenEnvVar, token)
+	setenv(t, spaceliftKeyIDEnvVar, keyID)
+	setenv(t, spaceliftKeySecretEnvVar, keySecret)
+}
+
+// writeOutputs writes terraform output json to a temporary file.
+func writeOutputs(t *testing.T, content string) string {
+	t.Helper()
+
+	fn := filepath.Join(t.TempDir(), "outputs.json")
+	if err := ioutil.WriteFile(fn, []byte(content), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	return fn
+}
+
+func newAPIGenerateCmd(endpoint string, outputsFile string, name string) *generateCmd {
+	return &generateCmd{
+		contextName: name,
+		applyViaAPI: true,
+		apiEndpoint: endpoint,
+		outputsFile: outputsFile,
+		secrets:     config.SecretsSensitive,
+	}
+}
+
+// configValues returns the decoded json content of config element id in ctx.
+func configValues(t *testing.T, ctx *spacelift.Context, id string) map[string]interface{} {
+	t.Helper()
+
+	for _, element := range ctx.Config {
+		if element.ID != id {
+			continue
+		}
+
+		if element.Type != spacelift.ConfigTypeFileMount {
+			t.Fatalf("Config element %s has type %s, want %s", id, element.Type, spacelift.ConfigTypeFileMount)
+		}
+
+		content, err := base64.StdEncoding.DecodeString(*element.Value)
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", id, err)
+		}
+
+		values := map[string]interface{}{}
+		if err := json.Unmarshal(content, &values); err != nil {
+			t.Fatalf("Failed to parse %s: %v", id, err)
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+func TestRunViaAPICreatesContext(t *testing.T) {
+	server := fake.NewServer("token")
+	defer server.Close()
+
+	setSpaceliftCredentials(t, "token", "", "")
+
+	gc := newAPIGenerateCmd(server.URL, writeOutputs(t, terraformOutputs), "network-dev")
+	if err := gc.runViaAPI(); err != nil {
+		t.Fatalf("runViaAPI() error = %v", err)
+	}
+
+	ctx := server.Context("network-dev")
+	if ctx == nil {
+		t.Fatalf("Context network-dev was not created")
+	}
+
+	if ctx.Name != "network-dev" {
+		t.Errorf("Context name = %s, want network-dev", ctx.Name)
+	}
+
+	public := configValues(t, ctx, "ctx-network-dev.json")
+	if len(public) != 2 || public["vnet_id"] != "vnet-1" {
+		t.Errorf("Public file = %v, want vnet_id and subnets", public)
+	}
+
+	secrets := configValues(t, ctx, "ctx-network-dev-secrets.json")
+	if len(secrets) != 1 || secrets["password"] != "secret" {
+		t.Errorf("Secrets file = %v, want password", secrets)
+	}
+
+	for _, element := range ctx.Config {
+		if wantWriteOnly := strings.HasSuffix(element.ID, "-secrets.json"); element.WriteOnly != wantWriteOnly {
+			t.Errorf("Write only of %s = %v, want %v", element.ID, element.WriteOnly, wantWriteOnly)
+		}
+	}
+
+	// Values of write only files are hidden when read through the API
+	read, err := spacelift.NewClient(server.URL, "token").Context("network-dev")
+	if err != nil {
+		t.Fatalf("Context() error = %v", err)
+	}
+
+	for _, element := range read.Config {
+		if element.WriteOnly && element.Value != nil {
+			t.Errorf("Value of write only %s is not hidden", element.ID)
+		}
+	}
+}
+
+func TestRunViaAPIUpdatesContext(t *testing.T) {
+	server := fake.NewServer("token")
+	defer server.Close()
+
+	setSpaceliftCredentials(t, "token", "", "")
+
+	old := base64.StdEncoding.EncodeToString([]byte(`{"old": "value"}`))
+	server.AddContext(&spacelift.Context{
+		ID:   "network-dev",
+		Name: "network-dev",
+		Config: []*spacelift.ConfigElement{
+			{ID: "ctx-network-dev.json", Type: spacelift.ConfigTypeFileMount, Value: &old},
+			{ID: "ctx-network-dev-secrets.json", Type: spacelift.ConfigTypeFileMount, Value: &old, WriteOnly: true},
+			{ID: "OTHER", Type: "ENVIRONMENT_VARIABLE", Value: &old},
+		},
+	})
+
+	gc := newAPIGenerateCmd(server.URL, writeOutputs(t, terraformOutputs), "network-dev")
+	gc.exclude = []string{"password"}
+
+	if err := gc.runViaAPI(); err != nil {
+		t.Fatalf("runViaAPI() error = %v", err)
+	}
+
+	ctx := server.Context("network-dev")
+
+	public := configValues(t, ctx, "ctx-network-dev.json")
+	if len(public) != 2 || public["vnet_id"] != "vnet-1" {
+		t.Errorf("Public file = %v, want vnet_id and subnets", public)
+	}
+
+	if hasConfig(ctx, "ctx-network-dev-secrets.json") {
+		t.Errorf("Secrets file should be removed when there are no sensitive outputs")
+	}
+
+	if !hasConfig(ctx, "OTHER") {
+		t.Errorf("Config not written by spacectx should be kept")
+	}
+}
+
+func TestRunViaAPIWithAPIKey(t *testing.T) {
+	server := fake.NewServer("jwt")
+	defer server.Close()
+
+	server.APIKeys["key"] = "secret"
+	setSpaceliftCredentials(t, "", "key", "secret")
+
+	gc := newAPIGenerateCmd(server.URL, writeOutputs(t, terraformOutputs), "network-dev")
+	if err := gc.runViaAPI(); err != nil {
+		t.Fatalf("runViaAPI() error = %v", err)
+	}
+
+	if server.Context("network-dev") == nil {
+		t.Errorf("Context network-dev was not created")
+	}
+
+	setSpaceliftCredentials(t, "", "key", "wrong")
+
+	if err := gc.runViaAPI(); err == nil || !strings.Contains(err.Error(), "Failed to get token") {
+		t.Errorf("runViaAPI() with wrong secret error = %v, want Failed to get token", err)
+	}
+}
+
+func TestRunViaAPIErrors(t *testing.T) {
+	server := fake.NewServer("token")
+	defer server.Close()
+
+	outputs := writeOutputs(t, terraformOutputs)
+
+	setSpaceliftCredentials(t, "wrong", "", "")
+
+	err := newAPIGenerateCmd(server.URL, outputs, "network-dev").runViaAPI()
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("runViaAPI() with bad token error = %v, want unauthorized", err)
+	}
+
+	setSpaceliftCredentials(t, "token", "", "")
+
+	gc := newAPIGenerateCmd(server.URL, outputs, "network-dev")
+	gc.adopt = "handmade"
+
+	err = gc.runViaAPI()
+	if err == nil || !strings.Contains(err.Error(), "Context handmade to adopt not found") {
+		t.Errorf("runViaAPI() adopting missing context error = %v, want not found", err)
+	}
+
+	if server.Context("network-dev") != nil {
+		t.Errorf("Context should not be created when adopting a missing context")
+	}
+
+	setSpaceliftCredentials(t, "", "", "")
+
+	err = newAPIGenerateCmd(server.URL, outputs, "network-dev").runViaAPI()
+	if err == nil || !strings.Contains(err.Error(), "credentials are not set") {
+		t.Errorf("runViaAPI() without credentials error = %v, want credentials are not set", err)
+	}
+}
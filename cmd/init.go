@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/2ttech/spacectx/internal/spacelift"
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type initCmd struct {
+	dir             string
+	role            string
+	stack           string
+	contextName     string
+	contexts        []string
+	configFile      string
+	variableFile    string
+	providerSource  string
+	providerVersion string
+
+	in  *bufio.Reader
+	out io.Writer
+}
+
+const (
+	roleProducer = "producer"
+	roleConsumer = "consumer"
+
+	versionsFile = "versions.tf"
+)
+
+var (
+	initLong = templates.LongDesc(`Bootstrap a stack repository for use with spacectx, either as producer
+		generating a context or as consumer using contexts.
+
+		For producers a before_init hook running spacectx generate is added to .spacelift/config.yml, and
+		the spacelift provider is added to required_providers if not already declared. For consumers a
+		before_init hook running spacectx process is added, and a sample *.workspace.tfvars file is created.
+
+		Hooks are added to stack_defaults, or to the stack set with --stack. Existing settings and
+		comments in the config file are kept. Options not set by flags are asked for interactively.`)
+
+	initExample = templates.Examples(`
+		# Setup interactively
+		spacectx init
+
+		# Setup stack network-dev in folder network as producer
+		spacectx init network --role producer --stack network-dev
+
+		# Setup consumer using contexts network-dev and database-dev
+		spacectx init --role consumer --context network-dev --context database-dev
+	`)
+)
+
+func newInitCmd() *cobra.Command {
+	ic := &initCmd{}
+
+	initCmd := &cobra.Command{
+		Use:                   "init [DIR]",
+		Short:                 "Bootstrap stack repository for spacectx",
+		Long:                  initLong,
+		Example:               initExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ic.in = bufio.NewReader(cmd.InOrStdin())
+			ic.out = cmd.ErrOrStderr()
+
+			if err := ic.init(args); err != nil {
+				return err
+			}
+
+			return ic.run(args)
+		},
+	}
+
+	f := initCmd.Flags()
+	f.StringVar(&ic.role, "role", "", "role of stack, producer or consumer")
+	f.StringVar(&ic.stack, "stack", "", "id of stack in config file to add hooks to. defaults to stack_defaults")
+	f.StringVarP(&ic.contextName, "name", "n", "", "name of context generated by producer, defaults to same as stack name")
+	f.StringArrayVar(&ic.contexts, "context", []string{}, "name of context used by consumer, referenced in sample variable file. can be repeated")
+	f.StringVar(&ic.configFile, "config", spacelift.ConfigFile, "spacelift config file to write hooks to")
+	f.StringVar(&ic.providerSource, "provider-source", defaultProviderSource(), "source of spacelift provider added to required_providers")
+	f.StringVar(&ic.providerVersion, "provider-version", defaultProviderVersion(), "version constraint of spacelift provider added to required_providers")
+	f.StringVar(&ic.variableFile, "variable-file", "terraform.workspace.tfvars", "name of sample variable file created for consumers")
+
+	return initCmd
+}
+
+func (ic *initCmd) init(args []string) error {
+	if len(args) == 0 {
+		ic.dir = "."
+	} else {
+		ic.dir = args[0]
+	}
+
+	if ic.role == "" {
+		role, err := ic.prompt("Setup stack as producer or consumer", "")
+		if err != nil {
+			return err
+		}
+
+		ic.role = role
+	}
+
+	switch ic.role {
+	case roleProducer:
+		if ic.contextName == "" {
+			name, err := ic.prompt("Name of context, leave empty to use stack id", "")
+			if err != nil {
+				return err
+			}
+
+			ic.contextName = name
+		}
+	case roleConsumer:
+		if len(ic.contexts) == 0 {
+			names, err := ic.prompt("Contexts to use, separated by comma", "")
+			if err != nil {
+				return err
+			}
+
+			for _, name := range strings.Split(names, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					ic.contexts = append(ic.contexts, name)
+				}
+			}
+		}
+	case "":
+		return errors.Errorf("Role is not set, must be %s or %s", roleProducer, roleConsumer)
+	default:
+		return errors.Errorf("Unknown role %s, must be %s or %s", ic.role, roleProducer, roleConsumer)
+	}
+
+	return nil
+}
+
+func (ic *initCmd) run(args []string) error {
+	if ic.role == roleProducer {
+		hook := "spacectx generate"
+		if ic.contextName != "" {
+			hook = fmt.Sprintf("%s --name %s", hook, ic.contextName)
+		}
+
+		err := ic.patchConfig(hook, "spacectx generate creates spacelift resources, so the stack must be administrative", nil)
+		if err != nil {
+			return err
+		}
+
+		return ensureProviderRequirement(ic.dir, spaceliftProvider(ic.providerSource, ic.providerVersion), true, "")
+	}
+
+	// Labelling the stack with context names attaches contexts generated with
+	// --autoattach, which is only done for a single stack
+	labels := []string{}
+	if ic.stack != "" {
+		labels = ic.contexts
+	}
+
+	if err := ic.patchConfig(processHookCommand, "", labels); err != nil {
+		return err
+	}
+
+	return ic.writeSampleVariables()
+}
+
+// prompt asks question and returns the answer, or value if no answer is given.
+func (ic *initCmd) prompt(question string, value string) (string, error) {
+	if value != "" {
+		fmt.Fprintf(ic.out, "%s [%s]: ", question, value)
+	} else {
+		fmt.Fprintf(ic.out, "%s: ", question)
+	}
+
+	answer, err := ic.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "Failed to read answer")
+	}
+
+	if err == io.EOF {
+		fmt.Fprintln(ic.out)
+	}
+
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer, nil
+	}
+
+	return value, nil
+}
+
+// patchConfig adds hook to before_init and labels to the stack in the spacelift
+// config file, creating the file if it does not exist. The comment is added to
+// the stack if it has none.
+func (ic *initCmd) patchConfig(hook string, comment string, labels []string) error {
+	doc := &yaml.Node{}
+
+	src, err := ioutil.ReadFile(ic.configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "Failed to read file %v", ic.configFile)
+	}
+
+	if err := yaml.Unmarshal(src, doc); err != nil {
+		return errors.Wrapf(err, "Failed to parse %s", ic.configFile)
+	}
+
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.Errorf("Invalid config file %s, expected a mapping", ic.configFile)
+	}
+
+	if key, _ := yamlMappingValue(root, "version"); key == nil {
+		root.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "version"},
+			{Kind: yaml.ScalarNode, Value: "1", Style: yaml.DoubleQuotedStyle},
+		}, root.Content...)
+	}
+
+	var key, stack *yaml.Node
+
+	if ic.stack == "" {
+		key, stack = yamlEnsureMapping(root, "stack_defaults")
+	} else {
+		_, stacks := yamlEnsureMapping(root, "stacks")
+		if stacks == nil {
+			return errors.Errorf("Invalid config file %s, expected stacks to be a mapping", ic.configFile)
+		}
+
+		key, stack = yamlEnsureMapping(stacks, ic.stack)
+
+		if projectRoot := filepath.ToSlash(filepath.Clean(ic.dir)); stack != nil && projectRoot != "." {
+			if k, _ := yamlMappingValue(stack, "project_root"); k == nil {
+				yamlSetScalar(stack, "project_root", projectRoot)
+			}
+		}
+	}
+
+	if stack == nil {
+		return errors.Errorf("Invalid config file %s, expected %s to be a mapping", ic.configFile, key.Value)
+	}
+
+	if comment != "" && key.HeadComment == "" {
+		key.HeadComment = fmt.Sprintf("# %s", comment)
+	}
+
+	hookAdded, err := yamlAppendUnique(stack, "before_init", hook)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid config file %s", ic.configFile)
+	}
+
+	if !hookAdded {
+		log.Printf("Hook %q already exists in %s", hook, ic.configFile)
+	}
+
+	labelsAdded := []string{}
+	for _, label := range labels {
+		added, err := yamlAppendUnique(stack, "labels", label)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid config file %s", ic.configFile)
+		}
+
+		if added {
+			labelsAdded = append(labelsAdded, label)
+		}
+	}
+
+	if !hookAdded && len(labelsAdded) == 0 {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(doc); err != nil {
+		return errors.Wrapf(err, "Failed to encode %s", ic.configFile)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ic.configFile), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Failed to create folder %s", filepath.Dir(ic.configFile))
+	}
+
+	if err := ioutil.WriteFile(ic.configFile, buf.Bytes(), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Failed to write file %s", ic.configFile)
+	}
+
+	if hookAdded {
+		log.Printf("Added hook %q to %s", hook, ic.configFile)
+	}
+
+	if len(labelsAdded) > 0 {
+		log.Printf("Added labels %s to stack %s in %s", strings.Join(labelsAdded, ", "), ic.stack, ic.configFile)
+	}
+
+	return nil
+}
+
+// writeSampleVariables creates a variable file showing how to reference the
+// contexts, unless it already exists.
+func (ic *initCmd) writeSampleVariables() error {
+	fn := filepath.Join(ic.dir, ic.variableFile)
+
+	if _, err := os.Stat(fn); err == nil {
+		log.Printf("File %s already exists, skipping", fn)
+		return nil
+	}
+
+	contexts := ic.contexts
+	if len(contexts) == 0 {
+		contexts = []string{"context_name"}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# Processed by %q in before_init hook.\n", processHookCommand)
+	fmt.Fprintf(buf, "# Values from attached contexts are referenced as context.<context name>.<output name>.\n")
+
+	for _, name := range contexts {
+		fmt.Fprintf(buf, "\n# variable_name = context.%s.output_name\n", name)
+	}
+
+	if err := ioutil.WriteFile(fn, buf.Bytes(), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Failed to write file %s", fn)
+	}
+
+	log.Printf("Created sample variable file %s", fn)
+
+	return nil
+}
+
+// yamlMappingValue returns key and value of key in mapping node, or nil if not found.
+func yamlMappingValue(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+
+	return nil, nil
+}
+
+// yamlEnsureMapping returns key and value of key in mapping node, adding an empty
+// mapping if not found. The value is nil if it exists but is not a mapping.
+func yamlEnsureMapping(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	k, v := yamlMappingValue(node, key)
+	if k == nil {
+		k = &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+		v = &yaml.Node{Kind: yaml.MappingNode}
+		node.Content = append(node.Content, k, v)
+	}
+
+	// An empty value, like "stack_defaults:" without content, is parsed as null
+	if v.Kind == yaml.ScalarNode && v.Tag == "!!null" {
+		*v = yaml.Node{Kind: yaml.MappingNode}
+	}
+
+	if v.Kind != yaml.MappingNode {
+		return k, nil
+	}
+
+	return k, v
+}
+
+// yamlAppendUnique appends value to the list key in mapping node, unless it is
+// already in the list. It returns true if value was added.
+func yamlAppendUnique(node *yaml.Node, key string, value string) (bool, error) {
+	k, list := yamlMappingValue(node, key)
+	if k == nil {
+		list = &yaml.Node{Kind: yaml.SequenceNode}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, list)
+	}
+
+	if list.Kind != yaml.SequenceNode {
+		return false, errors.Errorf("expected %s to be a list", key)
+	}
+
+	for _, existing := range list.Content {
+		if existing.Value == value {
+			return false, nil
+		}
+	}
+
+	list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: value})
+
+	return true, nil
+}
+
+func yamlSetScalar(node *yaml.Node, key string, value string) {
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: value},
+	)
+}
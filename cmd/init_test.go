@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copyFixture copies testdata/init/name to a temporary folder and returns its
+// path, or a path that does not exist if name is empty.
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	fn := filepath.Join(t.TempDir(), ".spacelift", "config.yml")
+	if name == "" {
+		return fn
+	}
+
+	src, err := ioutil.ReadFile(filepath.Join("testdata", "init", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fn), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(fn, src, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	return fn
+}
+
+func TestInitPatchConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		role    string
+		stack   string
+		golden  string
+		wantErr string
+	}{
+		{name: "no config file", role: roleProducer, golden: "producer_new.golden"},
+		{name: "existing stack_defaults", fixture: "stack_defaults.yml", role: roleProducer, golden: "stack_defaults.golden"},
+		{name: "empty stack_defaults", fixture: "null_defaults.yml", role: roleConsumer, golden: "null_defaults.golden"},
+		{name: "existing hook", fixture: "existing_hook.yml", role: roleConsumer, golden: "existing_hook.yml"},
+		{name: "stack with labels", fixture: "stacks.yml", role: roleConsumer, stack: "network-dev", golden: "stacks.golden"},
+		{name: "hooks not a list", fixture: "invalid.yml", role: roleConsumer, wantErr: "expected before_init to be a list"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			ic := &initCmd{
+				dir:         dir,
+				role:        tt.role,
+				stack:       tt.stack,
+				contextName: "network-dev",
+				contexts:    []string{"network-dev", "database-dev"},
+				configFile:  copyFixture(t, tt.fixture),
+			}
+
+			err := ic.run(nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			got, err := ioutil.ReadFile(ic.configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// project_root is set to the module folder relative to the repository
+			got = []byte(strings.ReplaceAll(string(got), filepath.ToSlash(dir), "network"))
+
+			checkGolden(t, filepath.Join("init", tt.golden), got)
+		})
+	}
+}
+
+func TestInitSampleVariables(t *testing.T) {
+	dir := t.TempDir()
+
+	ic := &initCmd{dir: dir, contexts: []string{"network-dev"}, variableFile: "terraform.workspace.tfvars"}
+	if err := ic.writeSampleVariables(); err != nil {
+		t.Fatalf("writeSampleVariables() error = %v", err)
+	}
+
+	checkGolden(t, filepath.Join("init", "sample.tfvars.golden"), []byte(readModuleFile(t, dir, ic.variableFile)))
+
+	// An existing file is kept
+	fn := filepath.Join(dir, ic.variableFile)
+	if err := ioutil.WriteFile(fn, []byte("vnet_id = context.network.vnet_id\n"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ic.writeSampleVariables(); err != nil {
+		t.Fatalf("writeSampleVariables() error = %v", err)
+	}
+
+	if got := readModuleFile(t, dir, ic.variableFile); got != "vnet_id = context.network.vnet_id\n" {
+		t.Errorf("Existing variable file was changed to:\n%s", got)
+	}
+}
+
+func TestInitPrompt(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		input   string
+		want    *initCmd
+		wantErr string
+	}{
+		{name: "producer", input: "producer\nnetwork-dev\n", want: &initCmd{role: roleProducer, contextName: "network-dev"}},
+		{name: "consumer", input: "consumer\nnetwork-dev, ,database-dev\n", want: &initCmd{role: roleConsumer, contexts: []string{"network-dev", "database-dev"}}},
+		{name: "no answer", input: "", wantErr: "Role is not set"},
+		{name: "unknown role", input: "other\n", wantErr: "Unknown role other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ic := &initCmd{in: bufio.NewReader(strings.NewReader(tt.input)), out: ioutil.Discard}
+
+			err := ic.init(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("init() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("init() error = %v", err)
+			}
+
+			if ic.role != tt.want.role || ic.contextName != tt.want.contextName || strings.Join(ic.contexts, ",") != strings.Join(tt.want.contexts, ",") {
+				t.Errorf("init() = %s %q %v, want %s %q %v", ic.role, ic.contextName, ic.contexts, tt.want.role, tt.want.contextName, tt.want.contexts)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/2ttech/spacectx/internal/helpers"
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type localsCmd struct {
+	files          string
+	outputFile     string
+	contextFolders []string
+}
+
+var (
+	localsLong = templates.LongDesc(`Generate terraform locals with values from context. The tf files are
+		searched for references like local.context.<name>.<key>, and a locals block holding the values
+		of each referenced context is written to the output file. Values from the secrets file are
+		wrapped in sensitive(). By default it searches all tf files in current folder.`)
+
+	localsExample = templates.Examples(`
+		# Generate locals for contexts referenced in current folder
+		spacectx locals
+
+		# Generate locals for module in another folder
+		spacectx locals ./module -o ./module/spacectx_context.auto.tf
+	`)
+)
+
+func newLocalsCmd() *cobra.Command {
+	lc := &localsCmd{}
+
+	localsCmd := &cobra.Command{
+		Use:                   "locals [FILE/DIR]",
+		Short:                 "Generate terraform locals with values from context",
+		Long:                  localsLong,
+		Example:               localsExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
+		Args:                  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := lc.init(args); err != nil {
+				return err
+			}
+
+			return lc.run(args)
+		},
+	}
+
+	f := localsCmd.Flags()
+	f.StringVarP(&lc.outputFile, "output", "o", "spacectx_context.auto.tf", "name of output file to create")
+	f.StringSliceVarP(&lc.contextFolders, "source-folder", "s", nil, "source folders to read context files from, searched in order. defaults to $SPACECTX_CONTEXT_PATH or current folder and spacelift workspace root")
+
+	return localsCmd
+}
+
+func (lc *localsCmd) init(args []string) error {
+	if len(args) == 0 {
+		lc.files = "."
+	} else {
+		lc.files = args[0]
+	}
+
+	return nil
+}
+
+func (lc *localsCmd) run(args []string) error {
+	files, err := helpers.ParseFiles(lc.files)
+	if err != nil {
+		return err
+	}
+
+	names, err := findLocalContextsInUse(files)
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		log.Printf("No context references found, skipping.")
+		return nil
+	}
+
+	folders := contextSearchPath(lc.contextFolders)
+	contexts := hclwrite.Tokens{}
+
+	for _, name := range names {
+		values, err := readContextFile(folders, fmt.Sprintf(contextFileName, name))
+		if err != nil {
+			return err
+		}
+
+		secrets, err := readContextFile(folders, fmt.Sprintf(contextSecretsFileName, name))
+		if err != nil {
+			return err
+		}
+
+		if values == nil && secrets == nil {
+			return errors.Errorf("No context files found for context %s", name)
+		}
+
+		contexts = append(contexts, objectItemTokens(name, contextTokens(values, secrets))...)
+	}
+
+	file := hclwrite.NewEmptyFile()
+	localsBlock := file.Body().AppendNewBlock("locals", []string{})
+	localsBlock.Body().SetAttributeRaw("context", objectTokens(contexts))
+
+	err = ioutil.WriteFile(lc.outputFile, hclwrite.Format(file.Bytes()), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Finished creating context locals file")
+
+	return nil
+}
+
+// findLocalContextsInUse returns the names of contexts referenced as
+// local.context.<name> in files.
+func findLocalContextsInUse(files []*hcl.File) ([]string, error) {
+	contexts := []string{}
+	seen := map[string]bool{}
+
+	for _, file := range files {
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+
+		var err error
+
+		hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+			if !ok || err != nil {
+				return nil
+			}
+
+			t := expr.Traversal
+			if t.RootName() != "local" || len(t) < 2 {
+				return nil
+			}
+
+			if attr, ok := t[1].(hcl.TraverseAttr); !ok || attr.Name != "context" {
+				return nil
+			}
+
+			if len(t) < 3 {
+				log.Warnf("[%s:%d] Reference to local.context without context name, skipping",
+					t.SourceRange().Filename, t.SourceRange().Start.Line)
+				return nil
+			}
+
+			name, nameErr := contextNameFromStep(t[2])
+			if nameErr != nil {
+				err = errors.Wrapf(nameErr, "Invalid reference on %s line %d", t.SourceRange().Filename, t.SourceRange().Start.Line)
+				return nil
+			}
+
+			if !seen[name] {
+				seen[name] = true
+				contexts = append(contexts, name)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(contexts)
+
+	return contexts, nil
+}
+
+// contextTokens builds the tokens for the values of a single context, with
+// secrets wrapped in sensitive().
+func contextTokens(values map[string]cty.Value, secrets map[string]cty.Value) hclwrite.Tokens {
+	keys := []string{}
+	sensitive := map[string]bool{}
+	all := map[string]cty.Value{}
+
+	for key, value := range values {
+		all[key] = value
+	}
+
+	for key, value := range secrets {
+		all[key] = value
+		sensitive[key] = true
+	}
+
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tokens := hclwrite.Tokens{}
+
+	for _, key := range keys {
+		valueTokens := hclwrite.TokensForValue(all[key])
+
+		if sensitive[key] {
+			valueTokens = append(hclwrite.Tokens{
+				{Type: hclsyntax.TokenIdent, Bytes: []byte(`sensitive`)},
+				{Type: hclsyntax.TokenOParen, Bytes: []byte{'('}},
+			}, valueTokens...)
+			valueTokens = append(valueTokens, &hclwrite.Token{Type: hclsyntax.TokenCParen, Bytes: []byte{')'}})
+		}
+
+		tokens = append(tokens, objectItemTokens(key, valueTokens)...)
+	}
+
+	return objectTokens(tokens)
+}
+
+// objectTokens wraps items in braces to make an object constructor.
+func objectTokens(items hclwrite.Tokens) hclwrite.Tokens {
+	tokens := hclwrite.Tokens{
+		{Type: hclsyntax.TokenOBrace, Bytes: []byte(`{`)},
+		{Type: hclsyntax.TokenNewline, Bytes: []byte{'\n'}},
+	}
+
+	tokens = append(tokens, items...)
+
+	return append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCBrace, Bytes: []byte(`}`)})
+}
+
+// objectItemTokens returns tokens for a single `key = value` item in an object
+// constructor. Keys that are not valid identifiers are quoted.
+func objectItemTokens(key string, value hclwrite.Tokens) hclwrite.Tokens {
+	var tokens hclwrite.Tokens
+
+	if hclsyntax.ValidIdentifier(key) {
+		tokens = hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: []byte(key)}}
+	} else {
+		tokens = hclwrite.TokensForValue(cty.StringVal(key))
+	}
+
+	tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenEqualOp, Bytes: []byte(`=`), SpacesBefore: 1})
+
+	if len(value) > 0 {
+		value[0].SpacesBefore = 1
+	}
+
+	tokens = append(tokens, value...)
+
+	return append(tokens, &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte{'\n'}})
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocals(t *testing.T) {
+	folder := writeContextFolder(t, map[string]string{
+		"ctx-net.json":         `{"vnet_id": "vnet-1", "subnets": ["a", "b"]}`,
+		"ctx-net-secrets.json": `{"password": "secret"}`,
+		"ctx-db.dev.json":      `{"host": "db"}`,
+	})
+
+	module := writeContextFolder(t, map[string]string{
+		"main.tf": `resource "x" "y" {
+  vnet_id  = local.context.net.vnet_id
+  password = local.context.net.password
+  host     = local.context["db.dev"].host
+  all      = [for s in local.context.net.subnets : upper(s)]
+}
+`,
+	})
+
+	out := filepath.Join(t.TempDir(), "spacectx_context.auto.tf")
+
+	lc := &localsCmd{outputFile: out, contextFolders: []string{folder}}
+	if err := lc.init([]string{module}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lc.run(nil); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	content, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `locals {
+  context = {
+    "db.dev" = {
+      host = "db"
+    }
+    net = {
+      password = sensitive("secret")
+      subnets  = ["a", "b"]
+      vnet_id  = "vnet-1"
+    }
+  }
+}
+`
+	if string(content) != want {
+		t.Errorf("run() =\n%s\nwant\n%s", content, want)
+	}
+}
+
+func TestLocalsErrors(t *testing.T) {
+	folder := writeContextFolder(t, map[string]string{
+		"ctx-corrupt.json": `{`,
+	})
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{"missing context", `output "a" { value = local.context.missing.key }`, "No context files found for context missing"},
+		{"corrupt context", `output "a" { value = local.context.corrupt.key }`, "Failed to read context file"},
+		{"invalid name", `output "a" { value = local.context["../net"].key }`, "Invalid context name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			module := writeContextFolder(t, map[string]string{"main.tf": tt.src})
+			out := filepath.Join(t.TempDir(), "out.tf")
+
+			lc := &localsCmd{files: module, outputFile: out, contextFolders: []string{folder}}
+
+			err := lc.run(nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("run() error = %v, want %s", err, tt.wantErr)
+			}
+
+			if _, err := os.Stat(out); err == nil {
+				t.Errorf("Output file should not be written on error")
+			}
+		})
+	}
+}
@@ -0,0 +1,543 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/2ttech/spacectx/internal/helpers"
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type migrateRemoteStateCmd struct {
+	dir           string
+	stacks        map[string]string
+	variablesFile string
+	tfvarsFile    string
+	dryRun        bool
+}
+
+// remoteStateRef is a reference to an output of a terraform_remote_state data
+// source, data.terraform_remote_state.<state>.outputs.<output>.
+type remoteStateRef struct {
+	state  string
+	output string
+}
+
+const (
+	remoteStateDataSource = "terraform_remote_state"
+)
+
+var (
+	migrateLong = templates.LongDesc(`Migrate existing configuration to use spacectx.`)
+
+	migrateRemoteStateLong = templates.LongDesc(`Migrate a module from terraform_remote_state data sources to
+		spacectx contexts. Every reference to data.terraform_remote_state.<state>.outputs.<output> is
+		replaced with a variable, the variable is declared, and an entry referencing the context is added
+		to the tfvars file. Data sources without remaining references are removed.
+
+		Variables already declared in the module are prefixed with the name of the remote state, and it
+		fails if that name is also taken.
+
+		Context names default to the name of the data source, use --stack to map them to stack names.
+		Finally it reports which outputs the producer stacks must define.`)
+
+	migrateRemoteStateExample = templates.Examples(`
+		# Migrate module in current folder
+		spacectx migrate remote-state
+
+		# Map remote state "network" to context of stack network-dev, and only report changes
+		spacectx migrate remote-state ./module --stack network=network-dev --dry-run
+	`)
+)
+
+func newMigrateCmd() *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate existing configuration to spacectx",
+		Long:  migrateLong,
+	}
+
+	migrateCmd.AddCommand(newMigrateRemoteStateCmd())
+
+	return migrateCmd
+}
+
+func newMigrateRemoteStateCmd() *cobra.Command {
+	mc := &migrateRemoteStateCmd{}
+
+	migrateRemoteStateCmd := &cobra.Command{
+		Use:                   "remote-state [DIR]",
+		Short:                 "Migrate terraform_remote_state references to context variables",
+		Long:                  migrateRemoteStateLong,
+		Example:               migrateRemoteStateExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := mc.init(args); err != nil {
+				return err
+			}
+
+			return mc.run(args)
+		},
+	}
+
+	f := migrateRemoteStateCmd.Flags()
+	f.StringToStringVar(&mc.stacks, "stack", nil, "context name to use for a remote state, in format state=context")
+	f.StringVar(&mc.variablesFile, "variables-file", "spacectx_variables.tf", "file in module folder to write variable declarations to")
+	f.StringVar(&mc.tfvarsFile, "tfvars-file", "terraform.workspace.tfvars", "file in module folder to add context references to")
+	f.BoolVar(&mc.dryRun, "dry-run", false, "only report changes, do not write any files")
+
+	return migrateRemoteStateCmd
+}
+
+func (mc *migrateRemoteStateCmd) init(args []string) error {
+	if len(args) == 0 {
+		mc.dir = "."
+	} else {
+		mc.dir = args[0]
+	}
+
+	info, err := os.Stat(mc.dir)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to stat %q", mc.dir)
+	}
+
+	if !info.IsDir() {
+		return errors.Errorf("%s is not a directory", mc.dir)
+	}
+
+	return nil
+}
+
+func (mc *migrateRemoteStateCmd) run(args []string) error {
+	names, err := helpers.ListFiles(mc.dir)
+	if err != nil {
+		return err
+	}
+
+	files := map[string]*hclwrite.File{}
+	refs := []*remoteStateRef{}
+	remaining := map[string]bool{}
+
+	for _, name := range names {
+		file, err := helpers.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		if file == nil {
+			continue
+		}
+
+		files[name] = file
+
+		found, unsupported := findRemoteStateRefs(file.BuildTokens(nil))
+		refs = append(refs, found...)
+
+		for _, state := range unsupported {
+			log.Warnf("[%s] Reference to remote state %s can not be migrated, only references to single outputs are supported", name, state)
+			remaining[state] = true
+		}
+	}
+
+	if len(refs) == 0 {
+		log.Printf("No remote state output references found, skipping.")
+		return nil
+	}
+
+	variables := remoteStateVariableNames(refs)
+
+	if err := mc.renameDeclaredVariables(variables, declaredVariables(files)); err != nil {
+		return err
+	}
+
+	for fn, file := range files {
+		tokens := replaceRemoteStateRefs(file.BuildTokens(nil), variables)
+
+		migrated, diags := hclwrite.ParseConfig(tokens.Bytes(), fn, hcl.Pos{Line: 1, Column: 1})
+		if err := checkDiags(diags); err != nil {
+			return errors.Wrapf(err, "Failed to parse migrated file %s", fn)
+		}
+
+		for _, block := range migrated.Body().Blocks() {
+			labels := block.Labels()
+			if block.Type() == "data" && len(labels) == 2 && labels[0] == remoteStateDataSource && !remaining[labels[1]] {
+				log.Printf("[%s] Removing data source %s.%s", fn, remoteStateDataSource, labels[1])
+				migrated.Body().RemoveBlock(block)
+			}
+		}
+
+		if mc.dryRun || string(migrated.Bytes()) == string(file.Bytes()) {
+			continue
+		}
+
+		content := bytes.TrimLeft(hclwrite.Format(migrated.Bytes()), "\n")
+
+		if err := ioutil.WriteFile(fn, content, os.ModePerm); err != nil {
+			return err
+		}
+
+		log.Printf("Updated %s", fn)
+	}
+
+	if err := mc.writeVariables(refs, variables); err != nil {
+		return err
+	}
+
+	if err := mc.writeTfvars(refs, variables); err != nil {
+		return err
+	}
+
+	mc.report(refs)
+
+	return nil
+}
+
+func (mc *migrateRemoteStateCmd) contextName(state string) string {
+	if name, ok := mc.stacks[state]; ok {
+		return name
+	}
+
+	return state
+}
+
+// renameDeclaredVariables renames variables clashing with variables already
+// declared in the module, using the name of the remote state as prefix. It fails
+// if the prefixed name is also taken. Variables declared in the variables file
+// are kept, as they are from an earlier migration.
+func (mc *migrateRemoteStateCmd) renameDeclaredVariables(variables map[remoteStateRef]string, declared map[string]string) error {
+	variablesFile := filepath.Join(mc.dir, mc.variablesFile)
+
+	taken := func(name string) (string, bool) {
+		fn, ok := declared[name]
+		return fn, ok && fn != variablesFile
+	}
+
+	refs := []remoteStateRef{}
+	for ref := range variables {
+		refs = append(refs, ref)
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return variables[refs[i]] < variables[refs[j]]
+	})
+
+	for _, ref := range refs {
+		name := variables[ref]
+
+		fn, ok := taken(name)
+		if !ok {
+			continue
+		}
+
+		renamed := fmt.Sprintf("%s_%s", ref.state, ref.output)
+
+		if _, ok := taken(renamed); ok || renamed == name || containsValue(variables, renamed) {
+			return errors.Errorf("Variable %s for data.%s.%s.outputs.%s is already declared in %s",
+				name, remoteStateDataSource, ref.state, ref.output, fn)
+		}
+
+		log.Printf("Variable %s is already declared in %s, using %s for data.%s.%s.outputs.%s",
+			name, fn, renamed, remoteStateDataSource, ref.state, ref.output)
+
+		variables[ref] = renamed
+	}
+
+	return nil
+}
+
+// declaredVariables returns the names of variables declared in files, with the
+// file declaring them.
+func declaredVariables(files map[string]*hclwrite.File) map[string]string {
+	declared := map[string]string{}
+
+	for fn, file := range files {
+		for _, block := range file.Body().Blocks() {
+			if block.Type() == "variable" && len(block.Labels()) == 1 {
+				declared[block.Labels()[0]] = filepath.Clean(fn)
+			}
+		}
+	}
+
+	return declared
+}
+
+func containsValue(variables map[remoteStateRef]string, name string) bool {
+	for _, value := range variables {
+		if value == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (mc *migrateRemoteStateCmd) writeVariables(refs []*remoteStateRef, variables map[remoteStateRef]string) error {
+	fn := filepath.Join(mc.dir, mc.variablesFile)
+
+	file, err := readOrCreateFile(fn)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		name := variables[*ref]
+
+		if file.Body().FirstMatchingBlock("variable", []string{name}) != nil {
+			continue
+		}
+
+		log.Printf("Declaring variable %s in %s", name, fn)
+
+		if len(file.Body().Blocks()) > 0 || len(file.Body().Attributes()) > 0 {
+			file.Body().AppendNewline()
+		}
+
+		block := file.Body().AppendNewBlock("variable", []string{name})
+		block.Body().SetAttributeValue("description", cty.StringVal(
+			fmt.Sprintf("Migrated from data.%s.%s.outputs.%s", remoteStateDataSource, ref.state, ref.output)))
+	}
+
+	if mc.dryRun {
+		return nil
+	}
+
+	return ioutil.WriteFile(fn, hclwrite.Format(file.Bytes()), os.ModePerm)
+}
+
+func (mc *migrateRemoteStateCmd) writeTfvars(refs []*remoteStateRef, variables map[remoteStateRef]string) error {
+	fn := filepath.Join(mc.dir, mc.tfvarsFile)
+
+	file, err := readOrCreateFile(fn)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		name := variables[*ref]
+
+		if file.Body().GetAttribute(name) != nil {
+			continue
+		}
+
+		log.Printf("Adding %s = context.%s.%s to %s", name, mc.contextName(ref.state), ref.output, fn)
+
+		file.Body().SetAttributeTraversal(name, contextTraversal(mc.contextName(ref.state), ref.output))
+	}
+
+	if mc.dryRun {
+		return nil
+	}
+
+	return ioutil.WriteFile(fn, hclwrite.Format(file.Bytes()), os.ModePerm)
+}
+
+func (mc *migrateRemoteStateCmd) report(refs []*remoteStateRef) {
+	outputs := map[string][]string{}
+
+	for _, ref := range refs {
+		name := mc.contextName(ref.state)
+		if !contains(outputs[name], ref.output) {
+			outputs[name] = append(outputs[name], ref.output)
+		}
+	}
+
+	names := []string{}
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sort.Strings(outputs[name])
+		log.Printf("Stack %s must export outputs: %v", name, outputs[name])
+	}
+}
+
+// readOrCreateFile reads hcl file fn, or returns an empty file if it does not exist.
+func readOrCreateFile(fn string) (*hclwrite.File, error) {
+	src, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return hclwrite.NewEmptyFile(), nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read file %s", fn)
+	}
+
+	file, diags := hclwrite.ParseConfig(src, fn, hcl.Pos{Line: 1, Column: 1})
+	if err := checkDiags(diags); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// contextTraversal returns the traversal context.<name>.<key>, using index syntax
+// for names that are not valid identifiers.
+func contextTraversal(name string, key string) hcl.Traversal {
+	traversal := hcl.Traversal{hcl.TraverseRoot{Name: "context"}}
+
+	for _, step := range []string{name, key} {
+		if hclsyntax.ValidIdentifier(step) {
+			traversal = append(traversal, hcl.TraverseAttr{Name: step})
+		} else {
+			traversal = append(traversal, hcl.TraverseIndex{Key: cty.StringVal(step)})
+		}
+	}
+
+	return traversal
+}
+
+// remoteStateVariableNames names the variable replacing each reference. The name
+// of the output is used, prefixed by the name of the remote state if several
+// remote states have outputs with the same name.
+func remoteStateVariableNames(refs []*remoteStateRef) map[remoteStateRef]string {
+	states := map[string]map[string]bool{}
+
+	for _, ref := range refs {
+		if states[ref.output] == nil {
+			states[ref.output] = map[string]bool{}
+		}
+
+		states[ref.output][ref.state] = true
+	}
+
+	names := map[remoteStateRef]string{}
+
+	for _, ref := range refs {
+		if len(states[ref.output]) > 1 {
+			names[*ref] = fmt.Sprintf("%s_%s", ref.state, ref.output)
+		} else {
+			names[*ref] = ref.output
+		}
+	}
+
+	return names
+}
+
+// findRemoteStateRefs returns the remote state output references in tokens, and
+// the names of remote states referenced in ways that can not be migrated.
+func findRemoteStateRefs(tokens hclwrite.Tokens) ([]*remoteStateRef, []string) {
+	refs := []*remoteStateRef{}
+	unsupported := []string{}
+
+	for i := 0; i < len(tokens); i++ {
+		state, ok := matchRemoteStatePrefix(tokens, i)
+		if !ok {
+			continue
+		}
+
+		ref, n := matchRemoteStateRef(tokens, i)
+		if ref == nil {
+			if !contains(unsupported, state) {
+				unsupported = append(unsupported, state)
+			}
+			continue
+		}
+
+		refs = append(refs, ref)
+		i += n - 1
+	}
+
+	return refs, unsupported
+}
+
+// replaceRemoteStateRefs replaces remote state output references in tokens with
+// references to variables.
+func replaceRemoteStateRefs(tokens hclwrite.Tokens, variables map[remoteStateRef]string) hclwrite.Tokens {
+	result := hclwrite.Tokens{}
+
+	for i := 0; i < len(tokens); i++ {
+		ref, n := matchRemoteStateRef(tokens, i)
+		if ref == nil {
+			result = append(result, tokens[i])
+			continue
+		}
+
+		result = append(result,
+			&hclwrite.Token{Type: hclsyntax.TokenIdent, Bytes: []byte("var"), SpacesBefore: tokens[i].SpacesBefore},
+			&hclwrite.Token{Type: hclsyntax.TokenDot, Bytes: []byte(".")},
+			&hclwrite.Token{Type: hclsyntax.TokenIdent, Bytes: []byte(variables[*ref])},
+		)
+
+		i += n - 1
+	}
+
+	return result
+}
+
+// matchRemoteStatePrefix checks if tokens at i starts with
+// data.terraform_remote_state.<state> and returns the name of the state.
+func matchRemoteStatePrefix(tokens hclwrite.Tokens, i int) (string, bool) {
+	if i > 0 && tokens[i-1].Type == hclsyntax.TokenDot {
+		return "", false
+	}
+
+	if !matchTokens(tokens, i, "data", ".", remoteStateDataSource, ".") {
+		return "", false
+	}
+
+	if i+4 >= len(tokens) || tokens[i+4].Type != hclsyntax.TokenIdent {
+		return "", false
+	}
+
+	return string(tokens[i+4].Bytes), true
+}
+
+// matchRemoteStateRef checks if tokens at i is a reference to a single output,
+// written as data.terraform_remote_state.<state>.outputs.<output> or with index
+// syntax outputs["<output>"]. Returns the reference and number of tokens used.
+func matchRemoteStateRef(tokens hclwrite.Tokens, i int) (*remoteStateRef, int) {
+	state, ok := matchRemoteStatePrefix(tokens, i)
+	if !ok {
+		return nil, 0
+	}
+
+	j := i + 5
+
+	if !matchTokens(tokens, j, ".", "outputs") {
+		return nil, 0
+	}
+
+	j += 2
+
+	switch {
+	case matchTokens(tokens, j, ".") && j+1 < len(tokens) && tokens[j+1].Type == hclsyntax.TokenIdent:
+		return &remoteStateRef{state: state, output: string(tokens[j+1].Bytes)}, j + 2 - i
+	case matchTokens(tokens, j, "[", "\"") && j+3 < len(tokens) &&
+		tokens[j+2].Type == hclsyntax.TokenQuotedLit && matchTokens(tokens, j+3, "\"", "]"):
+		return &remoteStateRef{state: state, output: string(tokens[j+2].Bytes)}, j + 5 - i
+	}
+
+	return nil, 0
+}
+
+// matchTokens checks if tokens starting at i have the given bytes.
+func matchTokens(tokens hclwrite.Tokens, i int, values ...string) bool {
+	if i+len(values) > len(tokens) {
+		return false
+	}
+
+	for k, value := range values {
+		if string(tokens[i+k].Bytes) != value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const migrateMainTf = `data "terraform_remote_state" "network" {
+  backend = "remote"
+}
+
+data "terraform_remote_state" "dns" {
+  backend = "remote"
+}
+
+resource "azurerm_subnet" "app" {
+  virtual_network_name = data.terraform_remote_state.network.outputs.vnet_name
+  address_prefixes     = [cidrsubnet(data.terraform_remote_state.network.outputs["address_space"][0], 8, 1)]
+  name                 = "${data.terraform_remote_state.network.outputs.prefix}-app"
+}
+
+locals {
+  zone_ids = { for k, v in data.terraform_remote_state.dns.outputs.zones : k => v.id }
+  rg       = data.terraform_remote_state.dns.outputs.resource_group
+  other_rg = data.terraform_remote_state.network.outputs.resource_group
+}
+`
+
+// writeModule writes files to a temporary module folder.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for fn, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func readModuleFile(t *testing.T, dir string, fn string) string {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func newTestMigrateCmd(dir string) *migrateRemoteStateCmd {
+	return &migrateRemoteStateCmd{
+		dir:           dir,
+		variablesFile: "spacectx_variables.tf",
+		tfvarsFile:    "terraform.workspace.tfvars",
+	}
+}
+
+func TestMigrateRemoteState(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.tf": migrateMainTf})
+
+	mc := newTestMigrateCmd(dir)
+	mc.stacks = map[string]string{"network": "network-dev"}
+
+	if err := mc.run(nil); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	want := map[string]string{
+		"main.tf": `resource "azurerm_subnet" "app" {
+  virtual_network_name = var.vnet_name
+  address_prefixes     = [cidrsubnet(var.address_space[0], 8, 1)]
+  name                 = "${var.prefix}-app"
+}
+
+locals {
+  zone_ids = { for k, v in var.zones : k => v.id }
+  rg       = var.dns_resource_group
+  other_rg = var.network_resource_group
+}
+`,
+		"spacectx_variables.tf": `variable "vnet_name" {
+  description = "Migrated from data.terraform_remote_state.network.outputs.vnet_name"
+}
+
+variable "address_space" {
+  description = "Migrated from data.terraform_remote_state.network.outputs.address_space"
+}
+
+variable "prefix" {
+  description = "Migrated from data.terraform_remote_state.network.outputs.prefix"
+}
+
+variable "zones" {
+  description = "Migrated from data.terraform_remote_state.dns.outputs.zones"
+}
+
+variable "dns_resource_group" {
+  description = "Migrated from data.terraform_remote_state.dns.outputs.resource_group"
+}
+
+variable "network_resource_group" {
+  description = "Migrated from data.terraform_remote_state.network.outputs.resource_group"
+}
+`,
+		"terraform.workspace.tfvars": `vnet_name              = context.network-dev.vnet_name
+address_space          = context.network-dev.address_space
+prefix                 = context.network-dev.prefix
+zones                  = context.dns.zones
+dns_resource_group     = context.dns.resource_group
+network_resource_group = context.network-dev.resource_group
+`,
+	}
+
+	for fn, content := range want {
+		if got := readModuleFile(t, dir, fn); got != content {
+			t.Errorf("Content of %s =\n%s\nwant\n%s", fn, got, content)
+		}
+	}
+
+	// Running again finds no references and leaves files unchanged
+	if err := mc.run(nil); err != nil {
+		t.Fatalf("run() again error = %v", err)
+	}
+
+	for fn, content := range want {
+		if got := readModuleFile(t, dir, fn); got != content {
+			t.Errorf("Content of %s changed when run again:\n%s", fn, got)
+		}
+	}
+}
+
+func TestMigrateRemoteStateUnsupportedRef(t *testing.T) {
+	main := `data "terraform_remote_state" "network" {
+  backend = "remote"
+}
+
+locals {
+  vnet_name = data.terraform_remote_state.network.outputs.vnet_name
+  all       = data.terraform_remote_state.network.outputs
+}
+`
+
+	dir := writeModule(t, map[string]string{"main.tf": main})
+
+	if err := newTestMigrateCmd(dir).run(nil); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	want := `data "terraform_remote_state" "network" {
+  backend = "remote"
+}
+
+locals {
+  vnet_name = var.vnet_name
+  all       = data.terraform_remote_state.network.outputs
+}
+`
+
+	if got := readModuleFile(t, dir, "main.tf"); got != want {
+		t.Errorf("Content of main.tf =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMigrateRemoteStateRenamesVariables(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables string
+		want      string
+		wantErr   string
+	}{
+		{
+			name:      "declared variable",
+			variables: `variable "vnet_name" {}`,
+			want:      "network_vnet_name = context.network.vnet_name\n",
+		},
+		{
+			name: "prefixed name also declared",
+			variables: `variable "vnet_name" {}
+variable "network_vnet_name" {}`,
+			wantErr: "Variable vnet_name for data.terraform_remote_state.network.outputs.vnet_name is already declared in",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeModule(t, map[string]string{
+				"main.tf":      `locals { vnet_name = data.terraform_remote_state.network.outputs.vnet_name }`,
+				"variables.tf": tt.variables,
+			})
+
+			err := newTestMigrateCmd(dir).run(nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %s", err, tt.wantErr)
+				}
+
+				if _, err := os.Stat(filepath.Join(dir, "terraform.workspace.tfvars")); !os.IsNotExist(err) {
+					t.Errorf("No files should be written when variable names clash")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			if got := readModuleFile(t, dir, "terraform.workspace.tfvars"); got != tt.want {
+				t.Errorf("Content of tfvars = %s, want %s", got, tt.want)
+			}
+
+			if got := readModuleFile(t, dir, "main.tf"); !strings.Contains(got, "var.network_vnet_name") {
+				t.Errorf("Content of main.tf = %s, want reference to var.network_vnet_name", got)
+			}
+		})
+	}
+}
+
+func TestMigrateRemoteStateDryRun(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.tf": migrateMainTf})
+
+	mc := newTestMigrateCmd(dir)
+	mc.dryRun = true
+
+	if err := mc.run(nil); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	if got := readModuleFile(t, dir, "main.tf"); got != migrateMainTf {
+		t.Errorf("main.tf was changed in dry run:\n%s", got)
+	}
+
+	for _, fn := range []string{"spacectx_variables.tf", "terraform.workspace.tfvars"} {
+		if _, err := os.Stat(filepath.Join(dir, fn)); !os.IsNotExist(err) {
+			t.Errorf("%s was written in dry run", fn)
+		}
+	}
+}
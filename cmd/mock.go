@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/2ttech/spacectx/internal/helpers"
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type mockCmd struct {
+	files       string
+	contextName string
+	outputDir   string
+}
+
+var (
+	mockLong = templates.LongDesc(`Generate context files with placeholder values based on the outputs
+		defined in tf files, for testing consumers without a real apply of the producer. The shape of
+		each value is inferred from the output expression, like list and map literals, for expressions,
+		function calls and names of resource attributes. By default it searches all tf files in current
+		folder.`)
+
+	mockExample = templates.Examples(`
+		# Generate mock context network-dev from producer module
+		spacectx mock ./producer-module --name network-dev
+
+		# Generate mock context in folder used by process
+		spacectx mock ../network -n network-dev -d .contexts
+		spacectx process terraform.workspace.tfvars -s .contexts
+	`)
+
+	mockListFunctions   = []string{"concat", "compact", "distinct", "flatten", "keys", "values", "reverse", "setproduct", "slice", "sort", "split", "tolist", "toset", "range", "chunklist", "setunion", "setintersection", "setsubtract", "matchkeys", "cidrsubnets"}
+	mockMapFunctions    = []string{"merge", "tomap", "zipmap", "transpose", "jsondecode", "yamldecode"}
+	mockNumberFunctions = []string{"length", "max", "min", "abs", "ceil", "floor", "parseint", "pow", "signum", "sum", "tonumber", "index"}
+	mockBoolFunctions   = []string{"alltrue", "anytrue", "can", "contains", "tobool", "fileexists", "startswith", "endswith", "issensitive"}
+	mockPassFunctions   = []string{"coalesce", "try", "sensitive", "nonsensitive", "one", "tostring"}
+)
+
+func newMockCmd() *cobra.Command {
+	mc := &mockCmd{}
+
+	mockCmd := &cobra.Command{
+		Use:                   "mock [FILE/DIR]",
+		Short:                 "Generate context files with placeholder values",
+		Long:                  mockLong,
+		Example:               mockExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
+		Args:                  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := mc.init(args); err != nil {
+				return err
+			}
+
+			return mc.run(args)
+		},
+	}
+
+	f := mockCmd.Flags()
+	f.StringVarP(&mc.contextName, "name", "n", "", "name of context to create, defaults to same as stack name")
+	f.StringVarP(&mc.outputDir, "output-dir", "d", ".", "folder to write context files to")
+
+	return mockCmd
+}
+
+func (mc *mockCmd) init(args []string) error {
+	if len(args) == 0 {
+		mc.files = "."
+	} else {
+		mc.files = args[0]
+	}
+
+	if mc.contextName == "" {
+		name, err := defaultContextName(mc.files)
+		if err != nil {
+			return err
+		}
+
+		mc.contextName = name
+	}
+
+	return nil
+}
+
+func (mc *mockCmd) run(args []string) error {
+	files, err := helpers.ReadFiles(mc.files)
+	if err != nil {
+		return err
+	}
+
+	definitions := []*outputDefinitions{}
+
+	for _, definition := range findOutputs(files) {
+		if selectOutput(definition.name, &definition.sensitive, projectConfig.Outputs.Include, projectConfig.Outputs.Exclude, projectConfig.Outputs.Secrets) {
+			definitions = append(definitions, definition)
+		}
+	}
+
+	if len(definitions) == 0 {
+		log.Printf("No outputs defined, skipping.")
+		return nil
+	}
+
+	outputs := []*contextOutput{}
+
+	for _, definition := range definitions {
+		src := definition.expr.BuildTokens(nil).Bytes()
+
+		expr, diags := hclsyntax.ParseExpression(src, fmt.Sprintf("<output %s>", definition.name), hcl.Pos{Line: 1, Column: 1})
+		if err := checkDiags(diags); err != nil {
+			return err
+		}
+
+		value := mockValue(definition.name, expr)
+
+		content, err := ctyjson.Marshal(value, value.Type())
+		if err != nil {
+			return errors.Wrapf(err, "Failed to encode mock value for %s", definition.name)
+		}
+
+		log.Debugf("Mock value for %s: %s", definition.name, content)
+
+		outputs = append(outputs, &contextOutput{
+			name:      definition.name,
+			sensitive: definition.sensitive,
+			value:     content,
+		})
+	}
+
+	return writeContextFiles(mc.outputDir, mc.contextName, outputs)
+}
+
+// mockValue returns a placeholder value with a shape inferred from expr. The name
+// is used for placeholder strings and to guess the type of attribute references.
+func mockValue(name string, expr hclsyntax.Expression) cty.Value {
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		if e.Val.IsNull() {
+			return mockString(name)
+		}
+		return e.Val
+	case *hclsyntax.TemplateExpr:
+		if e.IsStringLiteral() {
+			if value, diags := e.Value(nil); !diags.HasErrors() {
+				return value
+			}
+		}
+		return mockString(name)
+	case *hclsyntax.TemplateWrapExpr:
+		return mockValue(name, e.Wrapped)
+	case *hclsyntax.ParenthesesExpr:
+		return mockValue(name, e.Expression)
+	case *hclsyntax.TupleConsExpr:
+		values := []cty.Value{}
+		for i, item := range e.Exprs {
+			values = append(values, mockValue(fmt.Sprintf("%s_%d", name, i), item))
+		}
+		return cty.TupleVal(values)
+	case *hclsyntax.ObjectConsExpr:
+		values := map[string]cty.Value{}
+		for i, item := range e.Items {
+			key := mockObjectKey(item.KeyExpr)
+			if key == "" {
+				key = fmt.Sprintf("key%d", i+1)
+			}
+			values[key] = mockValue(key, item.ValueExpr)
+		}
+		return cty.ObjectVal(values)
+	case *hclsyntax.ForExpr:
+		value := mockValue(name, e.ValExpr)
+		if e.KeyExpr != nil {
+			return cty.ObjectVal(map[string]cty.Value{"key1": value})
+		}
+		return cty.TupleVal([]cty.Value{value})
+	case *hclsyntax.SplatExpr:
+		return cty.TupleVal([]cty.Value{mockValue(name, e.Each)})
+	case *hclsyntax.ConditionalExpr:
+		return mockValue(name, e.TrueResult)
+	case *hclsyntax.BinaryOpExpr:
+		if e.Op.Type == cty.Number {
+			return cty.NumberIntVal(1)
+		}
+		return cty.True
+	case *hclsyntax.UnaryOpExpr:
+		if e.Op.Type == cty.Number {
+			return cty.NumberIntVal(1)
+		}
+		return cty.True
+	case *hclsyntax.FunctionCallExpr:
+		return mockFunctionValue(name, e)
+	case *hclsyntax.ScopeTraversalExpr:
+		return mockTraversalValue(name, e.Traversal)
+	case *hclsyntax.RelativeTraversalExpr:
+		return mockTraversalValue(name, e.Traversal)
+	}
+
+	return mockString(name)
+}
+
+func mockFunctionValue(name string, call *hclsyntax.FunctionCallExpr) cty.Value {
+	switch {
+	case contains(mockListFunctions, call.Name):
+		element := mockString(name)
+		if len(call.Args) > 0 {
+			if value := mockValue(name, call.Args[0]); value.Type().IsTupleType() && value.LengthInt() > 0 {
+				return value
+			}
+		}
+		return cty.TupleVal([]cty.Value{element})
+	case contains(mockMapFunctions, call.Name):
+		if len(call.Args) > 0 {
+			if value := mockValue(name, call.Args[0]); value.Type().IsObjectType() {
+				return value
+			}
+		}
+		return cty.ObjectVal(map[string]cty.Value{"key1": mockString(name)})
+	case contains(mockNumberFunctions, call.Name):
+		return cty.NumberIntVal(1)
+	case contains(mockBoolFunctions, call.Name):
+		return cty.True
+	case contains(mockPassFunctions, call.Name) && len(call.Args) > 0:
+		return mockValue(name, call.Args[0])
+	}
+
+	return mockString(name)
+}
+
+// mockTraversalValue guesses the type of a reference from the name of the last
+// attribute, following common naming of resource attributes.
+func mockTraversalValue(name string, traversal hcl.Traversal) cty.Value {
+	attr := name
+
+	for i := len(traversal) - 1; i >= 0; i-- {
+		if step, ok := traversal[i].(hcl.TraverseAttr); ok {
+			attr = step.Name
+			break
+		}
+		if step, ok := traversal[i].(hcl.TraverseRoot); ok {
+			attr = step.Name
+			break
+		}
+	}
+
+	switch {
+	case attr == "tags" || attr == "labels" || attr == "metadata" || strings.HasSuffix(attr, "_map"):
+		return cty.ObjectVal(map[string]cty.Value{"key1": mockString(attr)})
+	case attr == "ids" || attr == "address_space" || strings.HasSuffix(attr, "_ids") || strings.HasSuffix(attr, "_addresses") ||
+		strings.HasSuffix(attr, "_prefixes") || strings.HasSuffix(attr, "_list") || strings.HasSuffix(attr, "_names") ||
+		strings.HasSuffix(attr, "_servers") || strings.HasSuffix(attr, "_cidrs"):
+		return cty.TupleVal([]cty.Value{mockString(attr)})
+	case attr == "count" || attr == "port" || attr == "size" || strings.HasSuffix(attr, "_count") ||
+		strings.HasSuffix(attr, "_port") || strings.HasSuffix(attr, "_size") || strings.HasSuffix(attr, "_number"):
+		return cty.NumberIntVal(1)
+	case attr == "enabled" || strings.HasPrefix(attr, "is_") || strings.HasPrefix(attr, "enable_") || strings.HasSuffix(attr, "_enabled"):
+		return cty.True
+	}
+
+	return mockString(attr)
+}
+
+func mockObjectKey(expr hclsyntax.Expression) string {
+	if key := hcl.ExprAsKeyword(expr); key != "" {
+		return key
+	}
+
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || !value.IsKnown() || value.Type() != cty.String {
+		return ""
+	}
+
+	return value.AsString()
+}
+
+func mockString(name string) cty.Value {
+	return cty.StringVal(fmt.Sprintf("mock-%s", name))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+func TestMockValue(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`"vnet-1"`, `"vnet-1"`},
+		{`null`, `"mock-out"`},
+		{`"${var.prefix}-vnet"`, `"mock-out"`},
+		{`azurerm_virtual_network.main.id`, `"mock-id"`},
+		{`azurerm_virtual_network.main.address_space`, `["mock-address_space"]`},
+		{`azurerm_subnet.main[*].id`, `["mock-id"]`},
+		{`azurerm_resource_group.main.tags`, `{"key1":"mock-tags"}`},
+		{`var.enable_ddos`, `true`},
+		{`var.node_count`, `1`},
+		{`[azurerm_subnet.app.id, "b"]`, `["mock-id","b"]`},
+		{`{ app = azurerm_subnet.app.id, port = 443 }`, `{"app":"mock-id","port":443}`},
+		{`{ for k, v in azurerm_subnet.main : k => v.id }`, `{"key1":"mock-id"}`},
+		{`[for s in azurerm_subnet.main : s.name]`, `["mock-name"]`},
+		{`var.enabled ? azurerm_subnet.main.id : null`, `"mock-id"`},
+		{`length(var.subnets)`, `1`},
+		{`concat(var.a, var.b)`, `["mock-out"]`},
+		{`merge(var.tags, { env = "dev" })`, `{"key1":"mock-tags"}`},
+		{`try(azurerm_subnet.main.id, "")`, `"mock-id"`},
+		{`var.count + 1`, `1`},
+	}
+
+	for _, tt := range tests {
+		expr, diags := hclsyntax.ParseExpression([]byte(tt.expr), "test", hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			t.Fatalf("Failed to parse %s: %v", tt.expr, diags)
+		}
+
+		value := mockValue("out", expr)
+
+		got, err := ctyjson.Marshal(value, value.Type())
+		if err != nil {
+			t.Fatalf("Failed to encode mock value of %s: %v", tt.expr, err)
+		}
+
+		if string(got) != tt.want {
+			t.Errorf("mockValue(%s) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestMock(t *testing.T) {
+	module := t.TempDir()
+
+	outputs := `
+output "vnet_id" {
+  value = azurerm_virtual_network.main.id
+}
+
+output "subnet_ids" {
+  value = { for k, v in azurerm_subnet.main : k => v.id }
+}
+
+output "password" {
+  value     = random_password.main.result
+  sensitive = true
+}
+`
+
+	if err := ioutil.WriteFile(filepath.Join(module, "outputs.tf"), []byte(outputs), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	mc := &mockCmd{files: module, contextName: "network-dev", outputDir: t.TempDir()}
+	if err := mc.run(nil); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	got := contextFolderContent(t, mc.outputDir)
+	want := map[string]string{
+		"ctx-network-dev.json":         `{"subnet_ids":{"key1":"mock-id"},"vnet_id":"mock-id"}`,
+		"ctx-network-dev-secrets.json": `{"password":"mock-result"}`,
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("Files written = %v, want %v", got, want)
+	}
+
+	for fn, content := range want {
+		if got[fn] != content {
+			t.Errorf("Content of %s = %s, want %s", fn, got[fn], content)
+		}
+	}
+}
+
+func TestMockErrors(t *testing.T) {
+	module := t.TempDir()
+
+	if err := ioutil.WriteFile(filepath.Join(module, "outputs.tf"), []byte(`output "vnet_id" {`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	mc := &mockCmd{files: module, contextName: "network-dev", outputDir: t.TempDir()}
+	if err := mc.run(nil); err == nil {
+		t.Errorf("run() with invalid tf file should fail")
+	}
+
+	mc = &mockCmd{files: filepath.Join(module, "missing"), contextName: "network-dev", outputDir: t.TempDir()}
+	if err := mc.run(nil); err == nil {
+		t.Errorf("run() with missing path should fail")
+	}
+
+	empty := t.TempDir()
+	mc = &mockCmd{files: empty, contextName: "network-dev", outputDir: t.TempDir()}
+	if err := mc.run(nil); err != nil {
+		t.Fatalf("run() without outputs error = %v", err)
+	}
+
+	if got := contextFolderContent(t, mc.outputDir); len(got) != 0 {
+		t.Errorf("Files written without outputs = %v, want none", got)
+	}
+
+	setenv(t, "TF_VAR_spacelift_stack_id", "")
+
+	if err := (&mockCmd{}).init([]string{empty}); err == nil || !strings.Contains(err.Error(), "context name is not set") {
+		t.Errorf("init() without name or stack error = %v, want context name is not set", err)
+	}
+}
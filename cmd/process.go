@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
@@ -12,23 +16,63 @@ import (
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	log "github.com/sirupsen/logrus"
 	"github.com/zclconf/go-cty/cty"
-	"github.com/zclconf/go-cty/cty/json"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 
+	"github.com/2ttech/spacectx/internal/config"
+	"github.com/2ttech/spacectx/internal/helpers"
 	"github.com/2ttech/spacectx/internal/templates"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 type processCmd struct {
-	outputFile    string
-	contextFolder string
-	ignoreError   bool
+	outputFile     string
+	outputFormat   string
+	contextFolders []string
+	ignoreError    bool
+	namingRules    []string
+
+	rules  []helpers.NamingRule
+	inputs []*processInput
+	loader *contextLoader
+	stdin  io.Reader
 }
 
+type processInput struct {
+	file   string
+	output string
+}
+
+const (
+	outputFormatHCL  = "hcl"
+	outputFormatJSON = "json"
+
+	stdinFileName = "-"
+)
+
 var (
 	processLong = templates.LongDesc(`Process tfvars file and replaces references to values from context
 		with values read from context files mounted on disk. If no context files are found it will default
-		to exit with error code.`)
+		to exit with error code, use --ignore-errors to set variables not found to null instead.
+
+		Both HCL (.tfvars) and JSON (.tfvars.json) variable files are supported. In JSON files context
+		values are referenced using string templates like "${context.name.key}". The output format is
+		decided by --output-format, or by the extension of the output file if not set.
+
+		Use - as file name to read from stdin. JSON syntax is detected from the content.
+
+		Multiple files, glob patterns or directories can be processed at once. Each result is then written
+		next to its input file, named according to the first matching --naming rule. Directories only
+		include files matching a naming rule. Each context is only read once, and failures are reported
+		after all files are processed.
+
+		If no files are given, the inputs set in the project configuration file are processed, writing a
+		single input to the output set there.`)
+
+	defaultNamingRules = []string{
+		"*.workspace.tfvars=*.auto.tfvars",
+		"*.workspace.tfvars.json=*.auto.tfvars.json",
+	}
 
 	processExample = templates.Examples(`
 		# Process test.tfvars file
@@ -37,8 +81,20 @@ var (
 		# Process test.tfvars file and output to processed.auto.tfvars
 		spacectx process test.tfvars -o processed.auto.tfvars
 
-		# Process test.tfvars file and ignore any errors
+		# Process test.tfvars file, setting variables not found in context to null
 		spacectx process test.tfvars --ignore-errors
+
+		# Process test.tfvars.json file and output as json to processed.auto.tfvars.json
+		spacectx process test.tfvars.json -o processed.auto.tfvars.json
+
+		# Process tfvars read from stdin
+		envsubst < test.tfvars | spacectx process -
+
+		# Process all *.workspace.tfvars files in current folder, writing each to *.auto.tfvars
+		spacectx process .
+
+		# Process files matching a pattern using a custom naming rule
+		spacectx process 'env/*.tmpl.tfvars' --naming '*.tmpl.tfvars=*.auto.tfvars'
 	`)
 )
 
@@ -46,15 +102,26 @@ func newProcessCmd() *cobra.Command {
 	pc := &processCmd{}
 
 	processCmd := &cobra.Command{
-		Use:                   "process",
+		Use:                   "process [FILE/DIR...]",
 		Short:                 "Process input file and replace variables from context",
 		Long:                  processLong,
 		Example:               processExample,
 		DisableFlagsInUseLine: true,
 		SilenceUsage:          true,
 		SilenceErrors:         true,
-		Args:                  cobra.ExactArgs(1),
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
+		Args:                  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			pc.stdin = cmd.InOrStdin()
+
+			if len(args) == 0 {
+				args = projectConfig.Process.Inputs
+
+				if pc.outputFile == "" {
+					pc.outputFile = projectConfig.Process.Output
+				}
+			}
+
 			if err := pc.init(args); err != nil {
 				return err
 			}
@@ -65,41 +132,202 @@ func newProcessCmd() *cobra.Command {
 
 	f := processCmd.Flags()
 	f.StringVarP(&pc.outputFile, "output", "o", "", "file to write processed result to. if not set it writes to stdout")
-	f.StringVarP(&pc.contextFolder, "source-folder", "s", ".", "source folder to read context files from")
-	f.BoolVar(&pc.ignoreError, "ignore-errors", false, "ignore any errors for variables not found")
+	f.StringVar(&pc.outputFormat, "output-format", "", "format of processed result, hcl or json. defaults to extension of output file")
+	f.StringSliceVarP(&pc.contextFolders, "source-folder", "s", nil, "source folders to read context files from, searched in order. defaults to $SPACECTX_CONTEXT_PATH or current folder and spacelift workspace root")
+	f.BoolVar(&pc.ignoreError, "ignore-errors", projectConfig.Process.IgnoreErrors, "set variables with values not found in context to null instead of failing")
+	f.StringArrayVar(&pc.namingRules, "naming", namingRulesDefault(), "rule for naming output files when processing multiple files, in format from=to")
 
 	return processCmd
 }
 
 func (pc *processCmd) init(args []string) error {
+	if len(args) == 0 {
+		return errors.Errorf("No input files given, and none set in %s", config.FileName)
+	}
 
-	fn := filepath.Clean(args[0])
+	if pc.outputFormat != "" && pc.outputFormat != outputFormatHCL && pc.outputFormat != outputFormatJSON {
+		return errors.Errorf("Unsupported output format %q, must be %s or %s", pc.outputFormat, outputFormatHCL, outputFormatJSON)
+	}
 
-	_, err := os.Lstat(fn)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to stat %v", fn)
+	for _, r := range pc.namingRules {
+		rule, err := helpers.ParseNamingRule(r)
+		if err != nil {
+			return err
+		}
+
+		pc.rules = append(pc.rules, rule)
+	}
+
+	batch := len(args) > 1
+
+	for _, arg := range args {
+		files, expanded, err := pc.expandInput(arg)
+		if err != nil {
+			return err
+		}
+
+		if expanded {
+			batch = true
+		}
+
+		for _, fn := range files {
+			pc.inputs = append(pc.inputs, &processInput{file: fn})
+		}
+	}
+
+	if len(pc.inputs) == 0 {
+		return errors.Errorf("No files found to process")
+	}
+
+	if batch {
+		if pc.outputFile != "" {
+			return errors.Errorf("Output file can not be set when processing multiple files")
+		}
+
+		for _, input := range pc.inputs {
+			if input.file == stdinFileName {
+				return errors.Errorf("Can not read from stdin when processing multiple files")
+			}
+		}
+
+		for _, input := range pc.inputs {
+			output, ok := pc.outputFileFor(input.file)
+			if !ok {
+				return errors.Errorf("No naming rule matches %s", input.file)
+			}
+
+			input.output = output
+		}
+	} else {
+		pc.inputs[0].output = pc.outputFile
 	}
 
-	if !strings.HasSuffix(fn, ".tfvars") {
-		return errors.Errorf("Can only process tfvars files")
+	for _, input := range pc.inputs {
+		log.Debugf("Input file %s, output file: %s", input.file, input.output)
 	}
 
-	log.Debugf("Output file: %s", pc.outputFile)
+	pc.loader = newContextLoader(pc.contextFolders)
 
 	return nil
 }
 
+// namingRulesDefault returns the naming rules from the project configuration file,
+// or the default rules if not set.
+func namingRulesDefault() []string {
+	if len(projectConfig.Process.Naming) > 0 {
+		return projectConfig.Process.Naming
+	}
+
+	return defaultNamingRules
+}
+
+// expandInput returns the variable files arg refers to. Directories and glob
+// patterns are expanded, in which case expanded is true. Directories only
+// include files matching one of the naming rules.
+func (pc *processCmd) expandInput(arg string) (files []string, expanded bool, err error) {
+	if arg == stdinFileName {
+		return []string{stdinFileName}, false, nil
+	}
+
+	if strings.ContainsAny(arg, "*?[") {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, true, errors.Wrapf(err, "Invalid pattern %q", arg)
+		}
+
+		for _, fn := range matches {
+			if isVariableFile(fn) {
+				files = append(files, fn)
+			}
+		}
+
+		return files, true, nil
+	}
+
+	fn := filepath.Clean(arg)
+
+	info, err := os.Lstat(fn)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "Failed to stat %v", fn)
+	}
+
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(fn)
+		if err != nil {
+			return nil, true, errors.Wrapf(err, "Failed to read directory %s", fn)
+		}
+
+		for _, entry := range entries {
+			name := filepath.Join(fn, entry.Name())
+			if _, ok := pc.outputFileFor(name); ok && entry.Mode().IsRegular() && isVariableFile(name) {
+				files = append(files, name)
+			}
+		}
+
+		return files, true, nil
+	}
+
+	if !isVariableFile(fn) {
+		return nil, false, errors.Errorf("Can only process tfvars or tfvars.json files")
+	}
+
+	return []string{fn}, false, nil
+}
+
+// outputFileFor returns the output file for fn using the first matching naming rule.
+func (pc *processCmd) outputFileFor(fn string) (string, bool) {
+	for _, rule := range pc.rules {
+		if output, ok := rule.Match(fn); ok {
+			return output, true
+		}
+	}
+
+	return "", false
+}
+
 func (pc *processCmd) run(args []string) error {
 
-	fn := args[0]
+	if len(pc.inputs) == 1 {
+		return pc.processInput(pc.inputs[0])
+	}
 
-	src, err := ioutil.ReadFile(fn)
+	failures := []string{}
+
+	for _, input := range pc.inputs {
+		if err := pc.processInput(input); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", input.file, err))
+			continue
+		}
+
+		log.Printf("Processed %s to %s", input.file, input.output)
+	}
+
+	if len(failures) > 0 {
+		return errors.Errorf("Failed to process %d of %d files:\n  %s", len(failures), len(pc.inputs), strings.Join(failures, "\n  "))
+	}
+
+	return nil
+}
+
+func (pc *processCmd) processInput(input *processInput) error {
+
+	fn := input.file
+
+	src, err := readSource(pc.stdin, fn)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to read file %v", fn)
+		return err
+	}
+
+	if fn == stdinFileName {
+		fn = "<stdin>"
+	}
+
+	if input.file == stdinFileName && bytes.HasPrefix(bytes.TrimSpace(src), []byte("{")) {
+		// A tfvars file can not start with a brace, so stdin is assumed to be json
+		fn = "<stdin>.json"
 	}
 
-	parser := hclparse.NewParser()
-	file, diags := parser.ParseHCL(src, fn)
+	file, diags := parseVariableFile(hclparse.NewParser(), src, fn)
 	if err := checkDiags(diags); err != nil {
 		return err
 	}
@@ -109,15 +337,15 @@ func (pc *processCmd) run(args []string) error {
 		return err
 	}
 
-	bytes, err := pc.processFile(file, evalContext)
+	bytes, err := pc.processFile(file, evalContext, pc.outputFormatFor(input.output))
 	if err != nil {
 		return err
 	}
 
-	if pc.outputFile == "" {
+	if input.output == "" {
 		fmt.Println(string(bytes))
 	} else {
-		if err := ioutil.WriteFile(pc.outputFile, bytes, os.ModePerm); err != nil {
+		if err := ioutil.WriteFile(input.output, bytes, os.ModePerm); err != nil {
 			return err
 		}
 	}
@@ -125,46 +353,135 @@ func (pc *processCmd) run(args []string) error {
 	return nil
 }
 
+// readSource reads file fn, or stdin if fn is -.
+func readSource(stdin io.Reader, fn string) ([]byte, error) {
+	if fn == stdinFileName {
+		src, err := ioutil.ReadAll(stdin)
+		return src, errors.Wrap(err, "Failed to read from stdin")
+	}
+
+	src, err := ioutil.ReadFile(fn)
+	return src, errors.Wrapf(err, "Failed to read file %v", fn)
+}
+
+// outputFormatFor returns the format set by flag, or guesses it from the
+// extension of the output file.
+func (pc *processCmd) outputFormatFor(fn string) string {
+	if pc.outputFormat != "" {
+		return pc.outputFormat
+	}
+
+	if strings.HasSuffix(fn, ".json") {
+		return outputFormatJSON
+	}
+
+	return outputFormatHCL
+}
+
 func (pc *processCmd) generateEvalContext(file *hcl.File) (*hcl.EvalContext, error) {
 	attrs, diags := file.Body.JustAttributes()
 	if err := checkDiags(diags); err != nil {
 		return nil, err
 	}
 
-	names, err := findContextsInUse(attrs)
-	if err != nil {
+	return pc.loader.evalContext(attrs)
+}
+
+func (pc *processCmd) processFile(file *hcl.File, context *hcl.EvalContext, format string) ([]byte, error) {
+	attrs, diags := file.Body.JustAttributes()
+	if err := checkDiags(diags); err != nil {
 		return nil, err
 	}
 
-	variables := map[string]cty.Value{}
+	names := []string{}
+	values := map[string]cty.Value{}
+
+	for _, attr := range sortedAttributes(attrs) {
+		value, diags := attr.Expr.Value(context)
+		if diags.HasErrors() && pc.ignoreError {
+			log.Warnf("Failed to evaluate %s, setting it to null: %v", attr.Name, diags)
+			value = cty.NullVal(cty.String)
+		} else if err := checkDiags(diags); err != nil {
+			return nil, err
+		}
 
+		names = append(names, attr.Name)
+		values[attr.Name] = value
+	}
+
+	if format == outputFormatJSON {
+		return encodeVariablesJSON(names, values)
+	}
+
+	result := hclwrite.NewEmptyFile()
 	for _, name := range names {
-		values := readContextFiles(pc.contextFolder, name)
-		variables[name] = values
+		result.Body().SetAttributeValue(name, values[name])
 	}
 
-	return &hcl.EvalContext{
-		Variables: map[string]cty.Value{
-			"context": cty.ObjectVal(variables),
-		},
-	}, nil
+	return result.Bytes(), nil
 }
 
-func (pc *processCmd) processFile(file *hcl.File, context *hcl.EvalContext) ([]byte, error) {
-	attrs, diags := file.Body.JustAttributes()
-	if err := checkDiags(diags); err != nil {
-		return nil, err
+func isVariableFile(fn string) bool {
+	return strings.HasSuffix(fn, ".tfvars") || strings.HasSuffix(fn, ".tfvars.json")
+}
+
+// parseVariableFile parses src as JSON if the file name ends with .json, and as
+// native HCL syntax otherwise.
+func parseVariableFile(parser *hclparse.Parser, src []byte, fn string) (*hcl.File, hcl.Diagnostics) {
+	if strings.HasSuffix(fn, ".json") {
+		return parser.ParseJSON(src, fn)
 	}
 
-	result := hclwrite.NewEmptyFile()
+	return parser.ParseHCL(src, fn)
+}
+
+// sortedAttributes returns attributes in the order they are defined in source.
+func sortedAttributes(attrs hcl.Attributes) []*hcl.Attribute {
+	sorted := make([]*hcl.Attribute, 0, len(attrs))
 	for _, attr := range attrs {
-		value, diags := attr.Expr.Value(context)
-		if err := checkDiags(diags); err != nil {
+		sorted = append(sorted, attr)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Range.Start.Byte < sorted[j].Range.Start.Byte
+	})
+
+	return sorted
+}
+
+// encodeVariablesJSON encodes values as a tfvars.json document, keeping names in
+// the order given.
+func encodeVariablesJSON(names []string, values map[string]cty.Value) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		key, err := json.Marshal(name)
+		if err != nil {
 			return nil, err
 		}
 
-		result.Body().SetAttributeValue(attr.Name, value)
+		value, err := ctyjson.Marshal(values[name], values[name].Type())
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to encode %s as json", name)
+		}
+
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+
+	buf.WriteString("}")
+
+	result := &bytes.Buffer{}
+	if err := json.Indent(result, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
 	}
+	result.WriteString("\n")
 
 	return result.Bytes(), nil
 }
@@ -185,84 +502,71 @@ func checkDiags(diags hcl.Diagnostics) error {
 }
 
 func findContextsInUse(attrs hcl.Attributes) ([]string, error) {
-	trav := []hcl.Traversal{}
-
-	for _, attr := range attrs {
-		vars := attr.Expr.Variables()
-		if len(vars) == 0 {
-			continue
-		}
-
-		trav = append(trav, vars...)
-	}
-
 	contexts := []string{}
 
-	for _, t := range trav {
-		if t.RootName() != "context" {
-			return nil, errors.Errorf("Does not support variables other than context")
-		}
-
-		expr := hclwrite.NewExpressionAbsTraversal(t)
-		tokens := expr.BuildTokens(nil)
-		name := string(tokens[2].Bytes)
+	for _, attr := range attrs {
+		for _, t := range attr.Expr.Variables() {
+			name, err := contextNameFromTraversal(t)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid reference in attribute %q on %s line %d",
+					attr.Name, t.SourceRange().Filename, t.SourceRange().Start.Line)
+			}
 
-		exists := false
+			exists := false
 
-		for _, ctx := range contexts {
-			if ctx == name {
-				exists = true
+			for _, ctx := range contexts {
+				if ctx == name {
+					exists = true
+				}
 			}
-		}
 
-		if !exists {
-			contexts = append(contexts, name)
+			if !exists {
+				contexts = append(contexts, name)
+			}
 		}
 	}
 
 	return contexts, nil
 }
 
-func unmarshalFile(fn string) (cty.Value, error) {
-	fn = filepath.Clean(fn)
-
-	_, err := os.Lstat(fn)
-	if err != nil {
-		return cty.NilVal, errors.Wrapf(err, "Failed to stat %q", fn)
+// contextNameFromTraversal returns the context name referenced by traversal. The
+// name is the first step after the context root, written either as an attribute
+// (context.name) or as a string index (context["name.with.dots"]).
+func contextNameFromTraversal(t hcl.Traversal) (string, error) {
+	if t.RootName() != "context" {
+		return "", errors.Errorf("Does not support variables other than context, found %q", t.RootName())
 	}
 
-	src, err := ioutil.ReadFile(fn)
-	if err != nil {
-		return cty.NilVal, errors.Wrapf(err, "Failed to read file %v", fn)
+	if len(t) < 2 {
+		return "", errors.Errorf("Missing context name after context")
 	}
 
-	ctype, err := json.ImpliedType(src)
-	if err != nil {
-		return cty.NilVal, err
-	}
-
-	return json.Unmarshal(src, ctype)
+	return contextNameFromStep(t[1])
 }
 
-func readContextFiles(folder string, name string) cty.Value {
-	files := []string{
-		filepath.Join(folder, fmt.Sprintf(contextFileName, name)),
-		filepath.Join(folder, fmt.Sprintf(contextSecretsFileName, name)),
-	}
-
-	variables := map[string]cty.Value{}
+// contextNameFromStep returns the context name written in traversal step.
+func contextNameFromStep(step hcl.Traverser) (string, error) {
+	var name string
 
-	for _, file := range files {
-		vars, err := unmarshalFile(file)
-		if err != nil {
-			log.Debugf("Context file %s not found, skipping", file)
-			continue
+	switch step := step.(type) {
+	case hcl.TraverseAttr:
+		name = step.Name
+	case hcl.TraverseIndex:
+		if step.Key.IsNull() || !step.Key.IsKnown() || step.Key.Type() != cty.String {
+			return "", errors.Errorf("Context name must be a string")
 		}
+		name = step.Key.AsString()
+	default:
+		return "", errors.Errorf("Unsupported context name syntax")
+	}
 
-		for k, v := range vars.AsValueMap() {
-			variables[k] = v
-		}
+	if name == "" {
+		return "", errors.Errorf("Context name cannot be empty")
+	}
+
+	if err := checkContextName(name); err != nil {
+		return "", err
 	}
 
-	return cty.ObjectVal(variables)
+	return name, nil
 }
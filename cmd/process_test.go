@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestContextNameFromTraversal(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{`context.network.vnet_id`, "network", false},
+		{`context.network-dev.vnet_id`, "network-dev", false},
+		{`context.network`, "network", false},
+		{`context["network.dev"].vnet_id`, "network.dev", false},
+		{`context["network"]["vnet_id"]`, "network", false},
+		{`context[""].vnet_id`, "", true},
+		{`context["../secrets"].vnet_id`, "", true},
+		{`context["nested/network"].vnet_id`, "", true},
+		{`context["nested\\network"].vnet_id`, "", true},
+		{`context[".."]`, "", true},
+		{`context[1].vnet_id`, "", true},
+		{`context`, "", true},
+		{`var.network`, "", true},
+		{`local.context.network`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			traversal, diags := hclsyntax.ParseTraversalAbs([]byte(tt.expr), "test", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatalf("Failed to parse %q: %v", tt.expr, diags)
+			}
+
+			got, err := contextNameFromTraversal(traversal)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("contextNameFromTraversal(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("contextNameFromTraversal(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextNameFromStep(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    hcl.Traverser
+		want    string
+		wantErr bool
+	}{
+		{"attribute", hcl.TraverseAttr{Name: "network"}, "network", false},
+		{"string index", hcl.TraverseIndex{Key: cty.StringVal("network.dev")}, "network.dev", false},
+		{"empty attribute", hcl.TraverseAttr{Name: ""}, "", true},
+		{"empty index", hcl.TraverseIndex{Key: cty.StringVal("")}, "", true},
+		{"null index", hcl.TraverseIndex{Key: cty.NullVal(cty.String)}, "", true},
+		{"unknown index", hcl.TraverseIndex{Key: cty.UnknownVal(cty.String)}, "", true},
+		{"number index", hcl.TraverseIndex{Key: cty.NumberIntVal(0)}, "", true},
+		{"splat", hcl.TraverseSplat{}, "", true},
+		{"parent folder", hcl.TraverseIndex{Key: cty.StringVal("../network")}, "", true},
+		{"path separator", hcl.TraverseIndex{Key: cty.StringVal("/etc/network")}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := contextNameFromStep(tt.step)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("contextNameFromStep() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("contextNameFromStep() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessFileIgnoreErrors(t *testing.T) {
+	src := []byte("missing = context.network.missing\nfound = context.network.vnet_id\n")
+
+	file, diags := hclparse.NewParser().ParseHCL(src, "test.tfvars")
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	evalContext := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"context": cty.ObjectVal(map[string]cty.Value{
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"vnet_id": cty.StringVal("vnet-1"),
+				}),
+			}),
+		},
+	}
+
+	if _, err := (&processCmd{}).processFile(file, evalContext, outputFormatHCL); err == nil {
+		t.Errorf("processFile() should fail for values not found")
+	}
+
+	result, err := (&processCmd{ignoreError: true}).processFile(file, evalContext, outputFormatJSON)
+	if err != nil {
+		t.Fatalf("processFile() with ignore errors error = %v", err)
+	}
+
+	want := "{\n  \"missing\": null,\n  \"found\": \"vnet-1\"\n}\n"
+	if string(result) != want {
+		t.Errorf("processFile() = %s, want %s", result, want)
+	}
+}
+
+func TestContextFilesOutsideFolder(t *testing.T) {
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "contexts")
+
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "ctx-outside.json"), []byte(`{"key": "value"}`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := findContextFile([]string{folder}, "../ctx-outside.json"); ok {
+		t.Errorf("findContextFile() should not find files outside folder")
+	}
+
+	outputs := []*contextOutput{{name: "key", value: []byte(`"value"`)}}
+
+	for _, name := range []string{"../outside", "nested/name", `nested\name`} {
+		if err := writeContextFiles(folder, name, outputs); err == nil {
+			t.Errorf("writeContextFiles(%q) should fail", name)
+		}
+	}
+
+	if err := writeContextFiles(folder, "network.dev", outputs); err != nil {
+		t.Errorf("writeContextFiles() error = %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/2ttech/spacectx/internal/helpers"
+	"github.com/pkg/errors"
+)
+
+// providerRequirement is an entry in required_providers.
+type providerRequirement struct {
+	name    string
+	source  string
+	version string
+}
+
+// providerDeclaration is where a provider is declared in a module. Nested is set
+// if it is only declared in a local module called by it.
+type providerDeclaration struct {
+	providerRequirement
+	file   string
+	nested bool
+}
+
+const (
+	spaceliftProviderSource = "spacelift-io/spacelift"
+)
+
+var (
+	terraformBlockSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "terraform"},
+			{Type: "module", LabelNames: []string{"name"}},
+		},
+	}
+
+	requiredProvidersSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "required_providers"},
+		},
+	}
+
+	moduleSourceSchema = &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "source"},
+		},
+	}
+)
+
+// spaceliftProvider returns the requirement for the spacelift provider, using
+// defaults for source and version if not set.
+func spaceliftProvider(source string, version string) *providerRequirement {
+	if source == "" {
+		source = spaceliftProviderSource
+	}
+
+	if version == "" {
+		version = fmt.Sprintf("~> %s", spaceliftProviderVersion)
+	}
+
+	return &providerRequirement{name: "spacelift", source: source, version: version}
+}
+
+// defaultProviderSource returns the spacelift provider source from the project
+// configuration file, or the default source.
+func defaultProviderSource() string {
+	if projectConfig.Provider.Source != "" {
+		return projectConfig.Provider.Source
+	}
+
+	return spaceliftProviderSource
+}
+
+// defaultProviderVersion returns the spacelift provider version constraint from
+// the project configuration file, or the default constraint.
+func defaultProviderVersion() string {
+	if projectConfig.Provider.Version != "" {
+		return projectConfig.Provider.Version
+	}
+
+	return fmt.Sprintf("~> %s", spaceliftProviderVersion)
+}
+
+func (r *providerRequirement) value() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"source":  cty.StringVal(r.source),
+		"version": cty.StringVal(r.version),
+	})
+}
+
+// findProviderDeclaration returns where provider name is declared in the module
+// in dir, or nil if it is not declared. Both native and JSON files are searched,
+// including override files, and local modules called by the module. The override
+// file written by spacectx is skipped, so it is always written with current
+// requirements.
+func findProviderDeclaration(dir string, name string) (*providerDeclaration, error) {
+	return findProviderDeclarationIn(dir, name, map[string]bool{})
+}
+
+func findProviderDeclarationIn(dir string, name string, visited map[string]bool) (*providerDeclaration, error) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		if visited[abs] {
+			return nil, nil
+		}
+
+		visited[abs] = true
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read directory %s", dir)
+	}
+
+	parser := hclparse.NewParser()
+	modules := []string{}
+
+	for _, entry := range entries {
+		fn := filepath.Join(dir, entry.Name())
+
+		if !entry.Mode().IsRegular() || entry.Name() == spaceliftOverrideFile {
+			continue
+		}
+
+		var file *hcl.File
+		var diags hcl.Diagnostics
+
+		switch {
+		case strings.HasSuffix(fn, ".tf"):
+			file, diags = parser.ParseHCLFile(fn)
+		case strings.HasSuffix(fn, ".tf.json"):
+			file, diags = parser.ParseJSONFile(fn)
+		default:
+			continue
+		}
+
+		if diags.HasErrors() {
+			return nil, errors.Wrapf(diags, "Failed to parse %s", fn)
+		}
+
+		content, _, _ := file.Body.PartialContent(terraformBlockSchema)
+
+		for _, block := range content.Blocks {
+			if block.Type == "module" {
+				if source := moduleSource(block); source != "" {
+					modules = append(modules, filepath.Join(dir, filepath.FromSlash(source)))
+				}
+
+				continue
+			}
+
+			if requirement := findRequiredProvider(block.Body, name); requirement != nil {
+				log.Debugf("Provider %s declared in %s", name, fn)
+				return &providerDeclaration{providerRequirement: *requirement, file: fn}, nil
+			}
+		}
+	}
+
+	for _, module := range modules {
+		declaration, err := findProviderDeclarationIn(module, name, visited)
+		if err != nil {
+			return nil, err
+		}
+
+		if declaration != nil {
+			declaration.nested = true
+			return declaration, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// findRequiredProvider returns the requirement for provider name in the body of
+// a terraform block, or nil if not declared.
+func findRequiredProvider(body hcl.Body, name string) *providerRequirement {
+	content, _, _ := body.PartialContent(requiredProvidersSchema)
+
+	for _, block := range content.Blocks {
+		attrs, _ := block.Body.JustAttributes()
+
+		attr, ok := attrs[name]
+		if !ok {
+			continue
+		}
+
+		requirement := &providerRequirement{name: name}
+
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || value.IsNull() || !value.IsKnown() {
+			return requirement
+		}
+
+		// Before terraform 0.13 requirements were only version constraints
+		if value.Type() == cty.String {
+			requirement.version = value.AsString()
+			return requirement
+		}
+
+		if value.Type().IsObjectType() {
+			if value.Type().HasAttribute("source") && value.GetAttr("source").Type() == cty.String {
+				requirement.source = value.GetAttr("source").AsString()
+			}
+			if value.Type().HasAttribute("version") && value.GetAttr("version").Type() == cty.String {
+				requirement.version = value.GetAttr("version").AsString()
+			}
+		}
+
+		return requirement
+	}
+
+	return nil
+}
+
+// moduleSource returns the source of a module block if it is a local path.
+func moduleSource(block *hcl.Block) string {
+	content, _, _ := block.Body.PartialContent(moduleSourceSchema)
+
+	attr, ok := content.Attributes["source"]
+	if !ok {
+		return ""
+	}
+
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || value.Type() != cty.String {
+		return ""
+	}
+
+	source := value.AsString()
+	if !strings.HasPrefix(source, "./") && !strings.HasPrefix(source, "../") {
+		return ""
+	}
+
+	return source
+}
+
+// writeProviderOverride writes requirement to the override file fn, which
+// terraform merges into the terraform block of the module.
+func writeProviderOverride(fn string, requirement *providerRequirement) error {
+	file := hclwrite.NewEmptyFile()
+
+	block := file.Body().AppendNewBlock("terraform", []string{})
+	providerBlock := block.Body().AppendNewBlock("required_providers", []string{})
+	providerBlock.Body().SetAttributeValue(requirement.name, requirement.value())
+
+	if err := ioutil.WriteFile(fn, file.Bytes(), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Failed to write file %s", fn)
+	}
+
+	log.Printf("Added provider %s requirement to %s", requirement.name, fn)
+
+	return nil
+}
+
+// mergeProviderRequirement adds requirement to the first terraform block found
+// in the tf files in dir, or to versions.tf if there is none.
+func mergeProviderRequirement(dir string, requirement *providerRequirement) error {
+	names, err := helpers.ListFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	var target *hclwrite.File
+	targetName := filepath.Join(dir, versionsFile)
+
+	for _, name := range names {
+		if strings.HasSuffix(name, "_override.tf") || filepath.Base(name) == "override.tf" {
+			continue
+		}
+
+		file, err := helpers.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		if file.Body().FirstMatchingBlock("terraform", []string{}) != nil {
+			target = file
+			targetName = name
+			break
+		}
+	}
+
+	if target == nil {
+		if target, err = helpers.ReadFile(targetName); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			return err
+		}
+
+		if target == nil {
+			target = hclwrite.NewEmptyFile()
+		}
+
+		target.Body().AppendNewBlock("terraform", []string{})
+	}
+
+	block := target.Body().FirstMatchingBlock("terraform", []string{})
+
+	providers := block.Body().FirstMatchingBlock("required_providers", []string{})
+	if providers == nil {
+		providers = block.Body().AppendNewBlock("required_providers", []string{})
+	}
+
+	providers.Body().SetAttributeValue(requirement.name, requirement.value())
+
+	if err := ioutil.WriteFile(targetName, hclwrite.Format(target.Bytes()), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Failed to write file %s", targetName)
+	}
+
+	log.Printf("Added provider %s to required_providers in %s", requirement.name, targetName)
+
+	return nil
+}
+
+// ensureProviderRequirement declares requirement in the module in dir, unless it
+// is already declared. If it is only declared in a nested module, the same
+// source and version are used to avoid conflicting requirements. The requirement
+// is merged into an existing terraform block if merge is set, otherwise it is
+// written to the override file in dir.
+func ensureProviderRequirement(dir string, requirement *providerRequirement, merge bool, overrideFile string) error {
+	declaration, err := findProviderDeclaration(dir, requirement.name)
+	if err != nil {
+		return err
+	}
+
+	if declaration != nil && !declaration.nested {
+		log.Printf("Provider %s already declared in %s", requirement.name, declaration.file)
+		return nil
+	}
+
+	if declaration == nil {
+		log.Printf("Provider %s is not declared in %s", requirement.name, dir)
+	} else {
+		log.Printf("Provider %s is only declared in nested module %s, using same requirement", requirement.name, declaration.file)
+
+		nested := *requirement
+		if declaration.source != "" {
+			nested.source = declaration.source
+		}
+		if declaration.version != "" {
+			nested.version = declaration.version
+		}
+
+		requirement = &nested
+	}
+
+	if merge {
+		return mergeProviderRequirement(dir, requirement)
+	}
+
+	return writeProviderOverride(filepath.Join(dir, overrideFile), requirement)
+}
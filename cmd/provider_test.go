@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnsureProviderRequirementWritesOverrideInDir(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "nested")
+
+	if err := os.MkdirAll(nested, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		filepath.Join(dir, "main.tf"): `module "nested" {
+  source = "./nested"
+}
+`,
+		filepath.Join(nested, "versions.tf"): `terraform {
+  required_providers {
+    spacelift = {
+      source  = "spacelift-io/spacelift"
+      version = "~> 0.9"
+    }
+  }
+}
+`,
+	}
+
+	for fn, content := range files {
+		if err := ioutil.WriteFile(fn, []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ensureProviderRequirement(dir, spaceliftProvider("", ""), false, spaceliftOverrideFile); err != nil {
+		t.Fatalf("ensureProviderRequirement() error = %v", err)
+	}
+
+	if _, err := os.Stat(spaceliftOverrideFile); err == nil {
+		t.Errorf("Override file should not be written to current folder")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, spaceliftOverrideFile))
+	if err != nil {
+		t.Fatalf("Override file not written to module folder: %v", err)
+	}
+
+	if !strings.Contains(string(content), `"~> 0.9"`) {
+		t.Errorf("Override file should use requirement of nested module, got:\n%s", content)
+	}
+}
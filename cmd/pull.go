@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/2ttech/spacectx/internal/spacelift"
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type pullCmd struct {
+	outputDir   string
+	apiEndpoint string
+}
+
+var (
+	pullLong = templates.LongDesc(`Download context files from the spacelift API for local use, to reproduce
+		a spacelift run without access to the mounted files. All context files in the context are written with
+		the same names as when mounted, also if they are not named after the context, like in contexts
+		adopted by generate. Write only files, like the secrets file generated by spacectx, can not be read
+		through the API and are skipped.`)
+
+	pullExample = templates.Examples(`
+		# Download context network-dev to current folder
+		spacectx pull network-dev
+
+		# Download several contexts to a separate folder
+		spacectx pull network-dev database-dev -d .contexts
+	`)
+)
+
+func newPullCmd() *cobra.Command {
+	pc := &pullCmd{}
+
+	pullCmd := &cobra.Command{
+		Use:                   "pull CONTEXT...",
+		Short:                 "Download context files from spacelift API",
+		Long:                  pullLong,
+		Example:               pullExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pc.run(args)
+		},
+	}
+
+	f := pullCmd.Flags()
+	f.StringVarP(&pc.outputDir, "output-dir", "d", ".", "folder to write context files to")
+	f.StringVar(&pc.apiEndpoint, "api-endpoint", "", "spacelift API endpoint, defaults to $SPACELIFT_API_KEY_ENDPOINT")
+
+	return pullCmd
+}
+
+func (pc *pullCmd) run(args []string) error {
+	client, err := newSpaceliftClient(pc.apiEndpoint)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(pc.outputDir, os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Failed to create folder %s", pc.outputDir)
+	}
+
+	for _, name := range args {
+		if err := pc.pullContext(client, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (pc *pullCmd) pullContext(client *spacelift.Client, name string) error {
+	ctx, err := client.Context(spacelift.Slug(name))
+	if err != nil {
+		return err
+	}
+
+	if ctx == nil {
+		return errors.Errorf("Context %s not found", name)
+	}
+
+	written := 0
+
+	for _, element := range ctx.Config {
+		fn := element.ID
+
+		if element.Type != spacelift.ConfigTypeFileMount || !isContextFile(fn) {
+			continue
+		}
+
+		if element.WriteOnly || element.Value == nil {
+			log.Warnf("File %s in context %s is write only, skipping", fn, ctx.ID)
+			continue
+		}
+
+		content, err := base64.StdEncoding.DecodeString(*element.Value)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to decode %s in context %s", fn, ctx.ID)
+		}
+
+		path := filepath.Join(pc.outputDir, fn)
+
+		if err := ioutil.WriteFile(path, content, os.ModePerm); err != nil {
+			return errors.Wrapf(err, "Failed to write file %s", path)
+		}
+
+		log.Printf("Wrote %s from context %s", path, ctx.ID)
+		written++
+	}
+
+	if written == 0 {
+		return errors.Errorf("No readable context files found in context %s", ctx.ID)
+	}
+
+	return nil
+}
+
+// isContextFile returns true if fn is named like a context file. Files are named
+// after the context name given to generate, which may differ from the name of the
+// context, so any context file is matched.
+func isContextFile(fn string) bool {
+	match, _ := path.Match(fmt.Sprintf(contextFileName, "*"), fn)
+
+	return match
+}
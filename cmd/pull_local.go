@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// contextNameIsRequired is returned by commands writing context files when no
+// name is given, as there is no stack to take the name from.
+var contextNameIsRequired = errors.Errorf("--name is required")
+
+type pullLocalCmd struct {
+	contextName string
+	fromDir     string
+	fromFile    string
+	outputDir   string
+}
+
+// terraformOutput is a single output as written by terraform output -json, which
+// is the same format used in state files and terraform show -json.
+type terraformOutput struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value"`
+}
+
+var (
+	pullLocalLong = templates.LongDesc(`Create context files for local development from the outputs of a
+		terraform module. Outputs are read by running terraform output -json in a module folder, or from
+		a file with saved output. Sensitive outputs are written to the secrets file, the same way as the
+		context generated by spacectx generate.`)
+
+	pullLocalExample = templates.Examples(`
+		# Create context network-dev from outputs of module in ../network
+		spacectx pull-local --name network-dev --from-dir ../network
+
+		# Create context from saved output
+		terraform output -json > outputs.json
+		spacectx pull-local --name network-dev --from-file outputs.json -d .contexts
+	`)
+)
+
+func newPullLocalCmd() *cobra.Command {
+	pc := &pullLocalCmd{}
+
+	pullLocalCmd := &cobra.Command{
+		Use:                   "pull-local",
+		Short:                 "Create context files from terraform outputs for local development",
+		Long:                  pullLocalLong,
+		Example:               pullLocalExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Args:                  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := pc.init(args); err != nil {
+				return err
+			}
+
+			return pc.run(args)
+		},
+	}
+
+	f := pullLocalCmd.Flags()
+	f.StringVarP(&pc.contextName, "name", "n", "", "name of context to create")
+	f.StringVar(&pc.fromDir, "from-dir", "", "module folder to run terraform output -json in")
+	f.StringVar(&pc.fromFile, "from-file", "", "file with output from terraform output -json")
+	f.StringVarP(&pc.outputDir, "output-dir", "d", ".", "folder to write context files to")
+
+	return pullLocalCmd
+}
+
+func (pc *pullLocalCmd) init(args []string) error {
+	if pc.contextName == "" {
+		return contextNameIsRequired
+	}
+
+	if (pc.fromDir == "") == (pc.fromFile == "") {
+		return errors.Errorf("Exactly one of --from-dir and --from-file must be set")
+	}
+
+	return nil
+}
+
+func (pc *pullLocalCmd) run(args []string) error {
+	var src []byte
+	var err error
+
+	if pc.fromFile != "" {
+		src, err = ioutil.ReadFile(pc.fromFile)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read file %v", pc.fromFile)
+		}
+	} else {
+		src, err = terraformOutputJSON(pc.fromDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	outputs, err := parseTerraformOutputs(src)
+	if err != nil {
+		return err
+	}
+
+	if len(outputs) == 0 {
+		log.Printf("No outputs found, skipping.")
+		return nil
+	}
+
+	return writeContextFiles(pc.outputDir, pc.contextName, outputs)
+}
+
+// terraformOutputJSON runs terraform output -json in dir.
+func terraformOutputJSON(dir string) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+
+	cmd := exec.Command("terraform", "output", "-json")
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
+	log.Debugf("Running terraform output -json in %s", dir)
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "Failed to run terraform output in %s", dir)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// parseTerraformOutputs parses the output of terraform output -json.
+func parseTerraformOutputs(src []byte) ([]*contextOutput, error) {
+	raw := map[string]*terraformOutput{}
+
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse terraform output")
+	}
+
+	return contextOutputs(raw), nil
+}
+
+// contextOutputs converts terraform outputs to context outputs sorted by name.
+// Outputs without a known value are skipped.
+func contextOutputs(raw map[string]*terraformOutput) []*contextOutput {
+	names := []string{}
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	outputs := []*contextOutput{}
+	for _, name := range names {
+		if raw[name] == nil || raw[name].Value == nil {
+			log.Warnf("Output %s has no known value, skipping", name)
+			continue
+		}
+
+		outputs = append(outputs, &contextOutput{
+			name:      name,
+			sensitive: raw[name].Sensitive,
+			value:     raw[name].Value,
+		})
+	}
+
+	return outputs
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// contextFolderContent returns the compacted json content of each file in dir.
+func contextFolderContent(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	for _, entry := range entries {
+		content, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		compact := &bytes.Buffer{}
+		if err := json.Compact(compact, content); err != nil {
+			t.Fatalf("File %s is not valid json: %v", entry.Name(), err)
+		}
+
+		files[entry.Name()] = compact.String()
+	}
+
+	return files
+}
+
+func TestPullLocal(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "sensitive outputs",
+			outputs: terraformOutputs,
+			want: map[string]string{
+				"ctx-network-dev.json":         `{"subnets":["a","b"],"vnet_id":"vnet-1"}`,
+				"ctx-network-dev-secrets.json": `{"password":"secret"}`,
+			},
+		},
+		{
+			name:    "no sensitive outputs",
+			outputs: `{"vnet_id": {"sensitive": false, "type": "string", "value": "vnet-1"}}`,
+			want:    map[string]string{"ctx-network-dev.json": `{"vnet_id":"vnet-1"}`},
+		},
+		{
+			name:    "unknown value",
+			outputs: `{"vnet_id": {"sensitive": false, "type": "string", "value": "vnet-1"}, "pending": {"sensitive": false}}`,
+			want:    map[string]string{"ctx-network-dev.json": `{"vnet_id":"vnet-1"}`},
+		},
+		{name: "no outputs", outputs: `{}`, want: map[string]string{}},
+		{name: "invalid json", outputs: `{`, wantErr: "Failed to parse terraform output"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := &pullLocalCmd{
+				contextName: "network-dev",
+				fromFile:    writeOutputs(t, tt.outputs),
+				outputDir:   t.TempDir(),
+			}
+
+			err := pc.run(nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			got := contextFolderContent(t, pc.outputDir)
+			if len(got) != len(tt.want) {
+				t.Errorf("Files written = %v, want %v", got, tt.want)
+			}
+
+			for fn, want := range tt.want {
+				if got[fn] != want {
+					t.Errorf("Content of %s = %s, want %s", fn, got[fn], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPullLocalErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pc      *pullLocalCmd
+		wantErr string
+	}{
+		{"name not set", &pullLocalCmd{fromFile: "outputs.json"}, "--name is required"},
+		{"no source", &pullLocalCmd{contextName: "network"}, "Exactly one of --from-dir and --from-file must be set"},
+		{"both sources", &pullLocalCmd{contextName: "network", fromDir: ".", fromFile: "outputs.json"}, "Exactly one of --from-dir and --from-file must be set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.pc.init(nil); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("init() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	pc := &pullLocalCmd{contextName: "network", fromFile: filepath.Join(t.TempDir(), "missing.json"), outputDir: t.TempDir()}
+	if err := pc.run(nil); err == nil || !strings.Contains(err.Error(), "Failed to read file") {
+		t.Errorf("run() with missing file error = %v, want Failed to read file", err)
+	}
+
+	pc = &pullLocalCmd{contextName: "../network", fromFile: writeOutputs(t, terraformOutputs), outputDir: t.TempDir()}
+	if err := pc.run(nil); err == nil {
+		t.Errorf("run() with path in name should fail")
+	}
+}
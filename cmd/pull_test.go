@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/2ttech/spacectx/internal/spacelift"
+	"github.com/2ttech/spacectx/internal/spacelift/fake"
+)
+
+func TestPull(t *testing.T) {
+	server := fake.NewServer("token")
+	defer server.Close()
+
+	public := base64.StdEncoding.EncodeToString([]byte(`{"vnet_id": "vnet-1"}`))
+	secret := base64.StdEncoding.EncodeToString([]byte(`{"password": "secret"}`))
+	other := base64.StdEncoding.EncodeToString([]byte(`other`))
+
+	server.AddContext(&spacelift.Context{
+		ID:   "network-dev",
+		Name: "network-dev",
+		Config: []*spacelift.ConfigElement{
+			{ID: "ctx-network-dev.json", Type: spacelift.ConfigTypeFileMount, Value: &public},
+			{ID: "ctx-network-dev-secrets.json", Type: spacelift.ConfigTypeFileMount, Value: &secret, WriteOnly: true},
+			{ID: "other.txt", Type: spacelift.ConfigTypeFileMount, Value: &other},
+		},
+	})
+
+	// Adopted contexts keep their name, while files are named after --name of generate
+	server.AddContext(&spacelift.Context{
+		ID:   "handmade",
+		Name: "handmade",
+		Config: []*spacelift.ConfigElement{
+			{ID: "ctx-network-prod.json", Type: spacelift.ConfigTypeFileMount, Value: &public},
+		},
+	})
+
+	server.AddContext(&spacelift.Context{
+		ID:   "secrets-only",
+		Name: "secrets-only",
+		Config: []*spacelift.ConfigElement{
+			{ID: "ctx-secrets-only-secrets.json", Type: spacelift.ConfigTypeFileMount, Value: &secret, WriteOnly: true},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		token   string
+		context string
+		files   []string
+		wantErr string
+	}{
+		{"public file", "token", "network-dev", []string{"ctx-network-dev.json"}, ""},
+		{"adopted context", "token", "handmade", []string{"ctx-network-prod.json"}, ""},
+		{"only write only files", "token", "secrets-only", nil, "No readable context files found in context secrets-only"},
+		{"context not found", "token", "missing", nil, "Context missing not found"},
+		{"bad token", "wrong", "network-dev", nil, "unauthorized"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setSpaceliftCredentials(t, tt.token, "", "")
+
+			pc := &pullCmd{outputDir: t.TempDir(), apiEndpoint: server.URL}
+
+			err := pc.run([]string{tt.context})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %s", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			entries, err := ioutil.ReadDir(pc.outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files := []string{}
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+
+			if strings.Join(files, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("Files written = %v, want %v", files, tt.files)
+			}
+
+			for _, fn := range tt.files {
+				content, err := ioutil.ReadFile(filepath.Join(pc.outputDir, fn))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(content) != `{"vnet_id": "vnet-1"}` {
+					t.Errorf("Content of %s = %s", fn, content)
+				}
+			}
+		})
+	}
+}
+
+func TestIsContextFile(t *testing.T) {
+	tests := []struct {
+		fn   string
+		want bool
+	}{
+		{"ctx-network.json", true},
+		{"ctx-network-secrets.json", true},
+		{"ctx-network.dev.json", true},
+		{"network.json", false},
+		{"ctx-network.txt", false},
+		{"dir/ctx-network.json", false},
+	}
+
+	for _, tt := range tests {
+		if got := isContextFile(tt.fn); got != tt.want {
+			t.Errorf("isContextFile(%q) = %v, want %v", tt.fn, got, tt.want)
+		}
+	}
+}
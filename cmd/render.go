@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+
+	"github.com/2ttech/spacectx/internal/templates"
+	"github.com/spf13/cobra"
+)
+
+type renderCmd struct {
+	outputFile     string
+	contextFolders []string
+
+	stdin io.Reader
+}
+
+var (
+	renderLong = templates.LongDesc(`Render a text file as a HCL template with values from context. Any
+		text file can be rendered, like kubernetes manifests, helm values or json config files.
+		Context values are referenced with interpolations like ${context.name.key}, and directives
+		like %{ for } and %{ if } are supported. Use $${ and %%{ to write literal ${ and %{.
+
+		The functions jsonencode, join, format, upper and lower are available in templates.`)
+
+	renderExample = templates.Examples(`
+		# Render helm values to stdout
+		spacectx render values.yaml.tmpl
+
+		# Render kubernetes manifest to file
+		spacectx render deployment.yaml.tmpl -o deployment.yaml
+
+		# Render template read from stdin
+		cat config.json.tmpl | spacectx render -
+	`)
+)
+
+func newRenderCmd() *cobra.Command {
+	rc := &renderCmd{}
+
+	renderCmd := &cobra.Command{
+		Use:                   "render FILE",
+		Short:                 "Render text file template with values from context",
+		Long:                  renderLong,
+		Example:               renderExample,
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		SilenceErrors:         true,
+		Annotations:           map[string]string{projectConfigAnnotation: ""},
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc.stdin = cmd.InOrStdin()
+
+			return rc.run(args)
+		},
+	}
+
+	f := renderCmd.Flags()
+	f.StringVarP(&rc.outputFile, "output", "o", "", "file to write rendered result to. if not set it writes to stdout")
+	f.StringSliceVarP(&rc.contextFolders, "source-folder", "s", nil, "source folders to read context files from, searched in order. defaults to $SPACECTX_CONTEXT_PATH or current folder and spacelift workspace root")
+
+	return renderCmd
+}
+
+func (rc *renderCmd) run(args []string) error {
+	fn := args[0]
+
+	src, err := readSource(rc.stdin, fn)
+	if err != nil {
+		return err
+	}
+
+	if fn == stdinFileName {
+		fn = "<stdin>"
+	}
+
+	expr, diags := hclsyntax.ParseTemplate(src, fn, hcl.Pos{Line: 1, Column: 1, Byte: 0})
+	if err := checkDiags(diags); err != nil {
+		return err
+	}
+
+	evalContext, err := newContextLoader(rc.contextFolders).evalContext(hcl.Attributes{
+		"template": &hcl.Attribute{Name: "template", Expr: expr, Range: expr.Range()},
+	})
+	if err != nil {
+		return err
+	}
+
+	evalContext.Functions = templateFunctions()
+
+	value, diags := expr.Value(evalContext)
+	if err := checkDiags(diags); err != nil {
+		return err
+	}
+
+	result, err := stringValue(value)
+	if err != nil {
+		return err
+	}
+
+	if rc.outputFile == "" {
+		fmt.Print(result)
+		return nil
+	}
+
+	log.Debugf("Writing rendered template to %s", rc.outputFile)
+
+	return ioutil.WriteFile(rc.outputFile, []byte(result), os.ModePerm)
+}
+
+func templateFunctions() map[string]function.Function {
+	return map[string]function.Function{
+		"format":     stdlib.FormatFunc,
+		"join":       stdlib.JoinFunc,
+		"jsonencode": stdlib.JSONEncodeFunc,
+		"lower":      stdlib.LowerFunc,
+		"upper":      stdlib.UpperFunc,
+	}
+}
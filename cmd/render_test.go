@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	folder := writeContextFolder(t, map[string]string{
+		"ctx-net.json":         `{"vnet_id": "vnet-1", "subnets": ["a", "b"]}`,
+		"ctx-net-secrets.json": `{"password": "secret"}`,
+	})
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "interpolation",
+			template: "vnet: ${context.net.vnet_id}\npassword: ${upper(context.net.password)}\n",
+			want:     "vnet: vnet-1\npassword: SECRET\n",
+		},
+		{
+			name:     "directives and functions",
+			template: "%{ for s in context.net.subnets }- ${s}\n%{ endfor }${jsonencode(context.net.subnets)}",
+			want:     "- a\n- b\n[\"a\",\"b\"]",
+		},
+		{
+			name:     "escaped markers",
+			template: "$${literal} %%{ literal }",
+			want:     "${literal} %{ literal }",
+		},
+		{
+			name:     "missing key",
+			template: "${context.net.missing}",
+			wantErr:  "Unsupported attribute",
+		},
+		{
+			name:     "other variables",
+			template: "${var.vnet_id}",
+			wantErr:  "Does not support variables other than context",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out")
+
+			rc := &renderCmd{
+				outputFile:     out,
+				contextFolders: []string{folder},
+				stdin:          strings.NewReader(tt.template),
+			}
+
+			err := rc.run([]string{stdinFileName})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			content, err := ioutil.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != tt.want {
+				t.Errorf("run() =\n%s\nwant\n%s", content, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/2ttech/spacectx/internal/config"
 	"github.com/2ttech/spacectx/internal/templates"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -14,25 +15,51 @@ var (
 
 var (
 	debug bool
+
+	// projectConfig holds defaults from the project configuration file
+	projectConfig = config.Empty()
 )
 
+// projectConfigAnnotation marks commands using the project configuration file,
+// which fail if it can not be read. Other commands, like help, ignore it.
+const projectConfigAnnotation = "spacectx/project-config"
+
 const (
 	contextFileName          = "ctx-%v.json"
 	contextSecretsFileName   = "ctx-%v-secrets.json"
-	spaceliftProviderVersion = "0.1.0"
+	spaceliftProviderVersion = "1.0"
+	tfeProviderVersion       = "0.40"
 	spaceliftOverrideFile    = "spacectx_override.tf"
 )
 
 // NewRootCmd returns the root command for utility
 func NewRootCmd() *cobra.Command {
+	// Loaded before commands are created, as values are used as flag defaults
+	cfg, cfgErr := config.Load(".")
+	projectConfig = cfg
+
 	rootCmd := &cobra.Command{
 		Use:   "spacectx",
 		Short: "Spacectx generates spacelift context and manage manage variable files.",
 		Long:  rootLong,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if debug {
 				log.SetLevel(log.DebugLevel)
 			}
+
+			if cfgErr != nil {
+				if _, ok := cmd.Annotations[projectConfigAnnotation]; ok {
+					return cfgErr
+				}
+
+				log.Warnf("Ignoring project configuration: %v", cfgErr)
+			}
+
+			if projectConfig.Path != "" {
+				log.Debugf("Using project configuration %s", projectConfig.Path)
+			}
+
+			return nil
 		},
 	}
 
@@ -41,6 +68,18 @@ func NewRootCmd() *cobra.Command {
 
 	rootCmd.AddCommand(newGenerateCmd())
 	rootCmd.AddCommand(newProcessCmd())
+	rootCmd.AddCommand(newExecCmd())
+	rootCmd.AddCommand(newEnvCmd())
+	rootCmd.AddCommand(newRenderCmd())
+	rootCmd.AddCommand(newExportCmd())
+	rootCmd.AddCommand(newLocalsCmd())
+	rootCmd.AddCommand(newExternalCmd())
+	rootCmd.AddCommand(newPullLocalCmd())
+	rootCmd.AddCommand(newFromStateCmd())
+	rootCmd.AddCommand(newMockCmd())
+	rootCmd.AddCommand(newMigrateCmd())
+	rootCmd.AddCommand(newPullCmd())
+	rootCmd.AddCommand(newInitCmd())
 
 	return rootCmd
 }
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/2ttech/spacectx/internal/config"
+)
+
+// chdir changes the working directory to dir for the duration of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		projectConfig = config.Empty()
+	})
+}
+
+func TestRootCmdInvalidProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, config.FileName), []byte(`outputs {`), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"help"}, ""},
+		{[]string{"from-state", "missing.tfstate", "--name", "network"}, "Failed to read file"},
+		{[]string{"process", "terraform.workspace.tfvars"}, "Failed to parse"},
+		{[]string{"generate", "--name", "network"}, "Failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args[0], func(t *testing.T) {
+			root := NewRootCmd()
+			root.SetArgs(tt.args)
+			root.SetOut(ioutil.Discard)
+
+			err := root.Execute()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Execute() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Execute() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRootCmdProcessConfigOutput(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+
+	files := map[string]string{
+		config.FileName: `process {
+  inputs         = ["terraform.workspace.tfvars"]
+  output         = "terraform.auto.tfvars"
+  source_folders = ["."]
+}`,
+		"terraform.workspace.tfvars": "vnet_id = context.network.vnet_id\n",
+		"ctx-network.json":           `{"vnet_id": "vnet-1"}`,
+	}
+
+	for fn, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := NewRootCmd()
+	root.SetArgs([]string{"process"})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "terraform.auto.tfvars"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != "vnet_id = \"vnet-1\"\n" {
+		t.Errorf("Processed output = %s", got)
+	}
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// contextSink generates the resources passing outputs of a stack to its consumers.
+type contextSink interface {
+	// provider returns the requirement of the provider the resources use
+	provider() *providerRequirement
+	// build returns a file with the resources for outputs
+	build(outputs []*outputDefinitions) *hclwrite.File
+}
+
+// spaceliftContextSink writes outputs to files mounted in a spacelift context.
+// Resource addresses do not depend on the name, so renaming the context does not
+// replace it.
+type spaceliftContextSink struct {
+	contextName string
+	beforeInit  []string
+	labels      []string
+	adopt       string
+	requirement *providerRequirement
+	// previous holds the addresses in the previously generated file, which are
+	// moved if they differ. Nil if there is no such file.
+	previous *contextAddresses
+}
+
+// contextAddresses are the resource names of the context and its mounted files.
+// Names are empty for resources not generated.
+type contextAddresses struct {
+	context     string
+	file        string
+	secretsFile string
+}
+
+// stableContextAddresses are the resource names of generated contexts.
+var stableContextAddresses = &contextAddresses{
+	context:     "outputs",
+	file:        "out_sctx_content",
+	secretsFile: "out_sctx_content_secrets",
+}
+
+// spaceliftStackDependencySink makes every consumer depend on the stack with a
+// reference for each output, so no context is needed.
+type spaceliftStackDependencySink struct {
+	stackID     string
+	consumers   []string
+	inputPrefix string
+	requirement *providerRequirement
+}
+
+func (s *spaceliftContextSink) provider() *providerRequirement {
+	return s.requirement
+}
+
+func (s *spaceliftContextSink) build(outputs []*outputDefinitions) *hclwrite.File {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+
+	contextBlock := body.AppendNewBlock("resource", []string{"spacelift_context", stableContextAddresses.context})
+	contextBlock.Body().SetAttributeValue("name", cty.StringVal(s.contextName))
+	contextBlock.Body().SetAttributeValue("description", cty.StringVal("Auto generated context by spacectx"))
+
+	if len(s.beforeInit) > 0 {
+		hooks := []cty.Value{}
+		for _, hook := range s.beforeInit {
+			hooks = append(hooks, cty.StringVal(hook))
+		}
+
+		contextBlock.Body().SetAttributeValue("before_init", cty.ListVal(hooks))
+	}
+
+	if len(s.labels) > 0 {
+		labels := []cty.Value{}
+		for _, label := range s.labels {
+			labels = append(labels, cty.StringVal(label))
+		}
+
+		contextBlock.Body().SetAttributeValue("labels", cty.SetVal(labels))
+	}
+
+	if s.adopt != "" {
+		importBlock := body.AppendNewBlock("import", []string{})
+		importBlock.Body().SetAttributeTraversal("to", hcl.Traversal{
+			hcl.TraverseRoot{
+				Name: "spacelift_context",
+			},
+			hcl.TraverseAttr{
+				Name: stableContextAddresses.context,
+			},
+		})
+		importBlock.Body().SetAttributeValue("id", cty.StringVal(s.adopt))
+	}
+
+	if s.previous != nil {
+		appendMovedBlock(body, "spacelift_context", s.previous.context, stableContextAddresses.context)
+	}
+
+	appendOutputLocals(body, outputs)
+
+	if checkIfAny(outputs, func(o *outputDefinitions) bool { return !o.sensitive }) {
+		s.appendFileBlock(body, outputs, false, contextFileName, stableContextAddresses.file)
+		if s.previous != nil {
+			appendMovedBlock(body, "spacelift_mounted_file", s.previous.file, stableContextAddresses.file)
+		}
+	}
+	if checkIfAny(outputs, func(o *outputDefinitions) bool { return o.sensitive }) {
+		s.appendFileBlock(body, outputs, true, contextSecretsFileName, stableContextAddresses.secretsFile)
+		if s.previous != nil {
+			appendMovedBlock(body, "spacelift_mounted_file", s.previous.secretsFile, stableContextAddresses.secretsFile)
+		}
+	}
+
+	return file
+}
+
+// appendMovedBlock moves resource of type from address from to address to, unless
+// from is empty or the same address.
+func appendMovedBlock(body *hclwrite.Body, resourceType string, from string, to string) {
+	if from == "" || from == to {
+		return
+	}
+
+	movedBlock := body.AppendNewBlock("moved", []string{})
+	movedBlock.Body().SetAttributeTraversal("from", hcl.Traversal{
+		hcl.TraverseRoot{Name: resourceType},
+		hcl.TraverseAttr{Name: from},
+	})
+	movedBlock.Body().SetAttributeTraversal("to", hcl.Traversal{
+		hcl.TraverseRoot{Name: resourceType},
+		hcl.TraverseAttr{Name: to},
+	})
+}
+
+func (s *spaceliftContextSink) appendFileBlock(body *hclwrite.Body, outputs []*outputDefinitions, sensitive bool, fileName string, localAttributeName string) {
+	fileBlock := body.AppendNewBlock("resource", []string{"spacelift_mounted_file", localAttributeName})
+	fileBlock.Body().SetAttributeTraversal("context_id", hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "spacelift_context",
+		},
+		hcl.TraverseAttr{
+			Name: stableContextAddresses.context,
+		},
+		hcl.TraverseAttr{
+			Name: "id",
+		},
+	})
+	fileBlock.Body().SetAttributeValue("relative_path", cty.StringVal(fmt.Sprintf(fileName, s.contextName)))
+	fileBlock.Body().SetAttributeValue("write_only", cty.BoolVal(sensitive))
+	fileBlock.Body().SetAttributeTraversal("content", hcl.Traversal{
+		hcl.TraverseRoot{
+			Name: "local",
+		},
+		hcl.TraverseAttr{
+			Name: localAttributeName,
+		},
+	})
+
+	localsBlock := body.FirstMatchingBlock("locals", []string{})
+
+	unencodedName := fmt.Sprintf("%s_raw", localAttributeName)
+
+	localsBlock.Body().SetAttributeRaw(unencodedName, localsContent(outputs, sensitive).BuildTokens(nil))
+	localsBlock.Body().SetAttributeRaw(localAttributeName, localsContentEncoded(unencodedName).BuildTokens(nil))
+}
+
+func (s *spaceliftStackDependencySink) provider() *providerRequirement {
+	return s.requirement
+}
+
+func (s *spaceliftStackDependencySink) build(outputs []*outputDefinitions) *hclwrite.File {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+
+	for i, consumer := range s.consumers {
+		if i > 0 {
+			body.AppendNewline()
+		}
+
+		name := resourceName(consumer)
+
+		dependencyBlock := body.AppendNewBlock("resource", []string{"spacelift_stack_dependency", name})
+		dependencyBlock.Body().SetAttributeValue("stack_id", cty.StringVal(consumer))
+		dependencyBlock.Body().SetAttributeValue("depends_on_stack_id", cty.StringVal(s.stackID))
+
+		for _, output := range outputs {
+			body.AppendNewline()
+
+			referenceBlock := body.AppendNewBlock("resource", []string{"spacelift_stack_dependency_reference", fmt.Sprintf("%s_%s", name, output.name)})
+			referenceBlock.Body().SetAttributeTraversal("stack_dependency_id", hcl.Traversal{
+				hcl.TraverseRoot{
+					Name: "spacelift_stack_dependency",
+				},
+				hcl.TraverseAttr{
+					Name: name,
+				},
+				hcl.TraverseAttr{
+					Name: "id",
+				},
+			})
+			referenceBlock.Body().SetAttributeValue("output_name", cty.StringVal(output.name))
+			referenceBlock.Body().SetAttributeValue("input_name", cty.StringVal(s.inputPrefix+output.name))
+		}
+	}
+
+	return file
+}
+
+// appendOutputLocals adds a locals block with the value expression of each output.
+func appendOutputLocals(body *hclwrite.Body, outputs []*outputDefinitions) {
+	localsBlock := body.AppendNewBlock("locals", []string{})
+
+	for _, output := range outputs {
+		localsBlock.Body().SetAttributeRaw(fmt.Sprintf("out_%s", output.name), output.expr.BuildTokens(nil))
+	}
+}
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// sinkTestOutputs returns the output definitions of src.
+func sinkTestOutputs(t *testing.T, src string) []*outputDefinitions {
+	t.Helper()
+
+	file, diags := hclwrite.ParseConfig([]byte(src), "outputs.tf", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	return findOutputs([]*hclwrite.File{file})
+}
+
+// checkGolden compares got with the content of testdata/name, or writes it when
+// run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	fn := filepath.Join("testdata", name)
+
+	if *updateGolden {
+		if err := ioutil.WriteFile(fn, got, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("Generated file does not match %s:\n%s", fn, got)
+	}
+}
+
+// movedBlocks returns the moved blocks in src as from=to pairs.
+func movedBlocks(t *testing.T, src []byte) []string {
+	t.Helper()
+
+	file, diags := hclwrite.ParseConfig(src, "test.tf", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("Generated invalid file: %v\n%s", diags, src)
+	}
+
+	moves := []string{}
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "moved" {
+			continue
+		}
+
+		from := strings.TrimSpace(string(block.Body().GetAttribute("from").Expr().BuildTokens(nil).Bytes()))
+		to := strings.TrimSpace(string(block.Body().GetAttribute("to").Expr().BuildTokens(nil).Bytes()))
+		moves = append(moves, from+"="+to)
+	}
+
+	return moves
+}
+
+func TestSpaceliftContextSinkMovedBlocks(t *testing.T) {
+	outputs := sinkTestOutputs(t, `output "vnet_id" {
+  value = "vnet"
+}
+
+output "password" {
+  value     = "secret"
+  sensitive = true
+}
+`)
+
+	tests := []struct {
+		name     string
+		previous *contextAddresses
+		want     []string
+	}{
+		{name: "no previous file"},
+		{name: "stable addresses", previous: &contextAddresses{"outputs", "out_sctx_content", "out_sctx_content_secrets"}},
+		{
+			name:     "other addresses",
+			previous: &contextAddresses{"network_dev", "network_dev", "network_dev_secrets"},
+			want: []string{
+				"spacelift_context.network_dev=spacelift_context.outputs",
+				"spacelift_mounted_file.network_dev=spacelift_mounted_file.out_sctx_content",
+				"spacelift_mounted_file.network_dev_secrets=spacelift_mounted_file.out_sctx_content_secrets",
+			},
+		},
+		{
+			name:     "no previous secrets file",
+			previous: &contextAddresses{context: "network", file: "network"},
+			want: []string{
+				"spacelift_context.network=spacelift_context.outputs",
+				"spacelift_mounted_file.network=spacelift_mounted_file.out_sctx_content",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &spaceliftContextSink{
+				contextName: "network-dev",
+				previous:    tt.previous,
+				requirement: spaceliftProvider("", ""),
+			}
+
+			got := movedBlocks(t, sink.build(outputs).Bytes())
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Moved blocks =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestPreviousContext(t *testing.T) {
+	generated := (&spaceliftContextSink{contextName: "network", requirement: spaceliftProvider("", "")}).build(sinkTestOutputs(t, `output "vnet_id" {
+  value = "vnet"
+}
+
+output "password" {
+  value     = "secret"
+  sensitive = true
+}
+`)).Bytes()
+
+	tests := []struct {
+		name     string
+		content  string
+		wantName string
+		want     *contextAddresses
+	}{
+		{name: "generated file", content: string(generated), wantName: "network", want: stableContextAddresses},
+		{
+			name: "other addresses",
+			content: `resource "spacelift_context" "network_dev" {
+  name = "network-dev"
+}
+
+resource "spacelift_mounted_file" "network_dev_secrets" {
+  context_id = spacelift_context.network_dev.id
+  write_only = true
+}
+
+resource "spacelift_mounted_file" "network_dev" {
+  context_id = spacelift_context.network_dev.id
+  write_only = false
+}
+`,
+			wantName: "network-dev",
+			want:     &contextAddresses{"network_dev", "network_dev", "network_dev_secrets"},
+		},
+		{name: "no context", content: `resource "spacelift_mounted_file" "file" {}`},
+		{name: "invalid file", content: `resource "spacelift_context" "outputs" {`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "spacelift_context.tf")
+			if err := ioutil.WriteFile(fn, []byte(tt.content), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			name, got := previousContext(fn)
+			if name != tt.wantName {
+				t.Errorf("previousContext() name = %q, want %q", name, tt.wantName)
+			}
+
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("previousContext() addresses = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if name, got := previousContext(filepath.Join(t.TempDir(), "missing.tf")); name != "" || got != nil {
+		t.Errorf("previousContext() of missing file = %q, %+v, want none", name, got)
+	}
+}
+
+func TestSpaceliftContextSinkAdopt(t *testing.T) {
+	sink := &spaceliftContextSink{
+		contextName: "network-dev",
+		adopt:       "handmade",
+		requirement: spaceliftProvider("", ""),
+	}
+
+	file, diags := hclwrite.ParseConfig(sink.build(nil).Bytes(), "test.tf", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	block := file.Body().FirstMatchingBlock("import", []string{})
+	if block == nil {
+		t.Fatalf("No import block generated")
+	}
+
+	to := strings.TrimSpace(string(block.Body().GetAttribute("to").Expr().BuildTokens(nil).Bytes()))
+	if to != "spacelift_context.outputs" {
+		t.Errorf("Import to = %s, want spacelift_context.outputs", to)
+	}
+
+	id := strings.TrimSpace(string(block.Body().GetAttribute("id").Expr().BuildTokens(nil).Bytes()))
+	if id != `"handmade"` {
+		t.Errorf("Import id = %s, want \"handmade\"", id)
+	}
+}
+
+func TestSpaceliftStackDependencySink(t *testing.T) {
+	outputs := sinkTestOutputs(t, `output "vnet_id" {
+  value = azurerm_virtual_network.main.id
+}
+
+output "subnet_ids" {
+  value = { for k, v in azurerm_subnet.main : k => v.id }
+}
+`)
+
+	sink := &spaceliftStackDependencySink{
+		stackID:     "Network Dev",
+		consumers:   []string{"app-dev", "db.dev"},
+		inputPrefix: "TF_VAR_",
+		requirement: spaceliftProvider("", ""),
+	}
+
+	checkGolden(t, "stack_dependency.golden", sink.build(outputs).Bytes())
+}
+
+func TestTFEVariableSetSink(t *testing.T) {
+	outputs := sinkTestOutputs(t, `output "vnet_id" {
+  value = azurerm_virtual_network.main.id
+}
+
+output "template" {
+  value = "$${name}-%%{if true}x%%{endif}"
+}
+
+output "password" {
+  value     = random_password.main.result
+  sensitive = true
+}
+`)
+
+	sink := &tfeVariableSetSink{name: "network-dev", organization: "my-org"}
+
+	checkGolden(t, "tfe_variable_set.golden", sink.build(outputs).Bytes())
+}
+
+func TestJsonencodeLocal(t *testing.T) {
+	tests := []cty.Value{
+		cty.StringVal("${name}-%{if true}x%{endif}"),
+		cty.StringVal("$${escaped} and 100%"),
+		cty.ObjectVal(map[string]cty.Value{"template": cty.StringVal("${var.x}"), "count": cty.NumberIntVal(2)}),
+	}
+
+	src := hclwrite.NewEmptyFile()
+	src.Body().SetAttributeRaw("value", jsonencodeLocal("out"))
+
+	file, diags := hclsyntax.ParseConfig(src.Bytes(), "test.tf", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	attrs, _ := file.Body.JustAttributes()
+
+	for _, want := range tests {
+		ctx := &hcl.EvalContext{
+			Variables: map[string]cty.Value{"local": cty.ObjectVal(map[string]cty.Value{"out": want})},
+			Functions: map[string]function.Function{"jsonencode": stdlib.JSONEncodeFunc, "replace": stdlib.ReplaceFunc},
+		}
+
+		value, diags := attrs["value"].Expr.Value(ctx)
+		if diags.HasErrors() {
+			t.Fatal(diags)
+		}
+
+		// The variable value is read as HCL by Terraform Cloud
+		expr, diags := hclsyntax.ParseExpression([]byte(value.AsString()), "value", hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			t.Fatalf("Value %s is not valid HCL: %v", value.AsString(), diags)
+		}
+
+		got, diags := expr.Value(nil)
+		if diags.HasErrors() {
+			t.Fatalf("Value %s can not be evaluated: %v", value.AsString(), diags)
+		}
+
+		if !got.Equals(want).True() {
+			t.Errorf("Value read by consumers = %#v, want %#v", got, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// tfeVariableSetSink writes outputs as variables in a Terraform Cloud variable set.
+// Values are written as HCL, so consumers get the same types as the outputs.
+type tfeVariableSetSink struct {
+	name         string
+	organization string
+}
+
+func (s *tfeVariableSetSink) provider() *providerRequirement {
+	return &providerRequirement{
+		name:    "tfe",
+		source:  "hashicorp/tfe",
+		version: fmt.Sprintf("~> %s", tfeProviderVersion),
+	}
+}
+
+func (s *tfeVariableSetSink) build(outputs []*outputDefinitions) *hclwrite.File {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body()
+
+	setBlock := body.AppendNewBlock("resource", []string{"tfe_variable_set", "outputs"})
+	setBlock.Body().SetAttributeValue("name", cty.StringVal(s.name))
+	setBlock.Body().SetAttributeValue("description", cty.StringVal("Auto generated variable set by spacectx"))
+	setBlock.Body().SetAttributeValue("organization", cty.StringVal(s.organization))
+
+	body.AppendNewline()
+	appendOutputLocals(body, outputs)
+
+	for _, output := range outputs {
+		body.AppendNewline()
+
+		variableBlock := body.AppendNewBlock("resource", []string{"tfe_variable", fmt.Sprintf("out_%s", output.name)})
+		variableBlock.Body().SetAttributeValue("key", cty.StringVal(output.name))
+		variableBlock.Body().SetAttributeRaw("value", jsonencodeLocal(fmt.Sprintf("out_%s", output.name)))
+		variableBlock.Body().SetAttributeValue("category", cty.StringVal("terraform"))
+		variableBlock.Body().SetAttributeValue("hcl", cty.True)
+		variableBlock.Body().SetAttributeValue("sensitive", cty.BoolVal(output.sensitive))
+		variableBlock.Body().SetAttributeTraversal("variable_set_id", hcl.Traversal{
+			hcl.TraverseRoot{
+				Name: "tfe_variable_set",
+			},
+			hcl.TraverseAttr{
+				Name: "outputs",
+			},
+			hcl.TraverseAttr{
+				Name: "id",
+			},
+		})
+	}
+
+	return file
+}
+
+// jsonencodeLocal returns tokens for jsonencode(local.name) with template markers
+// escaped. JSON is valid HCL, so it can be used as value of variables with hcl
+// set, but ${ and %{ in strings would be read as template sequences.
+func jsonencodeLocal(name string) hclwrite.Tokens {
+	tokens := functionCallTokens("jsonencode", hclwrite.TokensForTraversal(hcl.Traversal{
+		hcl.TraverseRoot{Name: "local"},
+		hcl.TraverseAttr{Name: name},
+	}))
+
+	for _, marker := range []string{"${", "%{"} {
+		escaped := marker[:1] + marker
+
+		tokens = functionCallTokens("replace", tokens, hclwrite.TokensForValue(cty.StringVal(marker)), hclwrite.TokensForValue(cty.StringVal(escaped)))
+	}
+
+	return tokens
+}
+
+// functionCallTokens returns tokens for a call of function name with args.
+func functionCallTokens(name string, args ...hclwrite.Tokens) hclwrite.Tokens {
+	tokens := hclwrite.Tokens{
+		{Type: hclsyntax.TokenIdent, Bytes: []byte(name)},
+		{Type: hclsyntax.TokenOParen, Bytes: []byte{'('}},
+	}
+
+	for i, arg := range args {
+		if i > 0 {
+			tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte{','}})
+		}
+
+		tokens = append(tokens, arg...)
+	}
+
+	return append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCParen, Bytes: []byte{')'}})
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/2ttech/spacectx/internal/spacelift"
+	"github.com/pkg/errors"
+)
+
+const (
+	spaceliftEndpointEnvVar  = "SPACELIFT_API_KEY_ENDPOINT"
+	spaceliftTokenEnvVar     = "SPACELIFT_API_TOKEN"
+	spaceliftKeyIDEnvVar     = "SPACELIFT_API_KEY_ID"
+	spaceliftKeySecretEnvVar = "SPACELIFT_API_KEY_SECRET"
+)
+
+// newSpaceliftClient returns a client for the spacelift API. The endpoint defaults
+// to SPACELIFT_API_KEY_ENDPOINT. It authenticates with SPACELIFT_API_TOKEN, which
+// is set in spacelift runs, or by exchanging the API key in SPACELIFT_API_KEY_ID
+// and SPACELIFT_API_KEY_SECRET for a token.
+func newSpaceliftClient(endpoint string) (*spacelift.Client, error) {
+	if endpoint == "" {
+		endpoint = os.Getenv(spaceliftEndpointEnvVar)
+	}
+
+	if endpoint == "" {
+		return nil, errors.Errorf("Spacelift API endpoint is not set, use --api-endpoint or %s", spaceliftEndpointEnvVar)
+	}
+
+	if token := os.Getenv(spaceliftTokenEnvVar); token != "" {
+		return spacelift.NewClient(endpoint, token), nil
+	}
+
+	keyID := os.Getenv(spaceliftKeyIDEnvVar)
+	keySecret := os.Getenv(spaceliftKeySecretEnvVar)
+
+	if keyID == "" || keySecret == "" {
+		return nil, errors.Errorf("Spacelift API credentials are not set, set %s or %s and %s",
+			spaceliftTokenEnvVar, spaceliftKeyIDEnvVar, spaceliftKeySecretEnvVar)
+	}
+
+	return spacelift.NewClientWithAPIKey(endpoint, keyID, keySecret)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/2ttech/spacectx/internal/spacelift"
+)
+
+// autoattachLabelPrefix makes spacelift attach a context to all stacks with the
+// label following the prefix.
+const autoattachLabelPrefix = "autoattach:"
+
+// currentStack returns the stack with files in folder of fn. In spacelift it is
+// set by TF_VAR_spacelift_stack_id, otherwise it is the stack in the spacelift
+// config file having the folder as project root. It returns nil if not found.
+func currentStack(fn string) (*spacelift.Stack, *spacelift.Config, error) {
+	dir := fn
+	if info, err := os.Stat(fn); err == nil && !info.IsDir() {
+		dir = filepath.Dir(fn)
+	}
+
+	config, err := spacelift.FindConfig(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if id := os.Getenv("TF_VAR_spacelift_stack_id"); id != "" {
+		if config != nil {
+			if stack := config.Stack(id); stack != nil {
+				return stack, config, nil
+			}
+		}
+
+		return &spacelift.Stack{ID: id}, config, nil
+	}
+
+	if config == nil {
+		log.Debugf("No spacelift config file found for %s", dir)
+		return nil, nil, nil
+	}
+
+	stack, err := config.StackForDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if stack != nil {
+		log.Debugf("Using stack %s from %s", stack.ID, filepath.Join(config.Root, spacelift.ConfigFile))
+	}
+
+	return stack, config, nil
+}
+
+// defaultContextName returns the name of the context generated from files in fn.
+// It is the id of the current stack, or the name template from the project
+// configuration file evaluated with the stack id.
+func defaultContextName(fn string) (string, error) {
+	stack, _, err := currentStack(fn)
+	if err != nil {
+		return "", err
+	}
+
+	id := ""
+	if stack != nil {
+		id = stack.ID
+	}
+
+	name := id
+
+	if projectConfig.Context.HasName() {
+		name, err = projectConfig.Context.ContextName(id)
+		if err != nil && id == "" {
+			log.Debugf("Context name template requires stack: %v", err)
+			return "", contextNameIsNotSet
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if name == "" {
+		return "", contextNameIsNotSet
+	}
+
+	log.Debugf("Using context name %s", name)
+
+	return name, nil
+}
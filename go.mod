@@ -11,4 +11,5 @@ require (
 	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/cobra v1.2.1
 	github.com/zclconf/go-cty v1.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,228 @@
+// Package config reads the spacectx project configuration file, holding defaults
+// for settings otherwise given as command line flags.
+package config
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FileName is the name of the project configuration file.
+const FileName = ".spacectx.hcl"
+
+const (
+	// SecretsSensitive writes sensitive outputs as secrets
+	SecretsSensitive = "sensitive"
+	// SecretsAll writes all outputs as secrets
+	SecretsAll = "all"
+	// SecretsNone writes no outputs as secrets
+	SecretsNone = "none"
+)
+
+// Config is the project configuration. All blocks are set after Load, even if
+// not present in the file.
+type Config struct {
+	Context  *Context  `hcl:"context,block"`
+	Provider *Provider `hcl:"provider,block"`
+	Outputs  *Outputs  `hcl:"outputs,block"`
+	Process  *Process  `hcl:"process,block"`
+
+	// Path is the file the configuration was read from, empty if none was found
+	Path string
+}
+
+// Context holds settings for generated contexts.
+type Context struct {
+	// Name is a template for the context name, with the stack id available as stack
+	Name hcl.Expression `hcl:"name,optional"`
+	// PreviousName is the name of the context before it was renamed
+	PreviousName string   `hcl:"previous_name,optional"`
+	Labels       []string `hcl:"labels,optional"`
+	Autoattach   bool     `hcl:"autoattach,optional"`
+}
+
+// Provider holds settings for the spacelift provider requirement.
+type Provider struct {
+	Source  string `hcl:"source,optional"`
+	Version string `hcl:"version,optional"`
+	// Merge adds the requirement to an existing terraform block instead of an override file
+	Merge bool `hcl:"merge,optional"`
+}
+
+// Outputs decides which outputs are added to contexts, and which of them are secrets.
+type Outputs struct {
+	Include []string `hcl:"include,optional"`
+	Exclude []string `hcl:"exclude,optional"`
+	Secrets string   `hcl:"secrets,optional"`
+}
+
+// Process holds defaults for processing variable files. Relative paths are
+// resolved from the folder of the configuration file.
+type Process struct {
+	Inputs []string `hcl:"inputs,optional"`
+	// Output is the file to write to when processing a single input, as naming
+	// rules are only used for several inputs
+	Output        string   `hcl:"output,optional"`
+	Naming        []string `hcl:"naming,optional"`
+	SourceFolders []string `hcl:"source_folders,optional"`
+	IgnoreErrors  bool     `hcl:"ignore_errors,optional"`
+}
+
+// Load searches for the configuration file in dir and its parents. An empty
+// configuration is returned if none is found.
+func Load(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Empty(), err
+	}
+
+	for {
+		fn := filepath.Join(dir, FileName)
+
+		if _, err := os.Stat(fn); err == nil {
+			return Read(fn)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Empty(), nil
+		}
+
+		dir = parent
+	}
+}
+
+// Read reads the configuration file fn.
+func Read(fn string) (*Config, error) {
+	file, diags := hclparse.NewParser().ParseHCLFile(fn)
+	if diags.HasErrors() {
+		return Empty(), errors.Wrapf(diags, "Failed to parse %s", fn)
+	}
+
+	config := &Config{}
+	if diags := gohcl.DecodeBody(file.Body, nil, config); diags.HasErrors() {
+		return Empty(), errors.Wrapf(diags, "Failed to read %s", fn)
+	}
+
+	config.Path = fn
+	config.setDefaults()
+
+	switch config.Outputs.Secrets {
+	case SecretsSensitive, SecretsAll, SecretsNone:
+	default:
+		return Empty(), errors.Errorf("Invalid secrets %q in %s, must be %s, %s or %s", config.Outputs.Secrets, fn, SecretsSensitive, SecretsAll, SecretsNone)
+	}
+
+	dir := filepath.Dir(fn)
+	config.Process.Inputs = resolvePaths(dir, config.Process.Inputs)
+	config.Process.SourceFolders = resolvePaths(dir, config.Process.SourceFolders)
+
+	if config.Process.Output != "" {
+		config.Process.Output = resolvePaths(dir, []string{config.Process.Output})[0]
+	}
+
+	return config, nil
+}
+
+// Empty returns a configuration with all settings unset.
+func Empty() *Config {
+	config := &Config{}
+	config.setDefaults()
+
+	return config
+}
+
+func (c *Config) setDefaults() {
+	if c.Context == nil {
+		c.Context = &Context{}
+	}
+	if c.Context.Name == nil {
+		c.Context.Name = hcl.StaticExpr(cty.NullVal(cty.String), hcl.Range{})
+	}
+	if c.Provider == nil {
+		c.Provider = &Provider{}
+	}
+	if c.Outputs == nil {
+		c.Outputs = &Outputs{}
+	}
+	if c.Outputs.Secrets == "" {
+		c.Outputs.Secrets = SecretsSensitive
+	}
+	if c.Process == nil {
+		c.Process = &Process{}
+	}
+}
+
+// HasName returns true if a context name template is set.
+func (c *Context) HasName() bool {
+	value, diags := c.Name.Value(nil)
+
+	return diags.HasErrors() || !value.IsNull()
+}
+
+// ContextName returns the context name for stack. Stack is null in the template
+// if empty.
+func (c *Context) ContextName(stack string) (string, error) {
+	stackValue := cty.NullVal(cty.String)
+	if stack != "" {
+		stackValue = cty.StringVal(stack)
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"stack": stackValue,
+		},
+	}
+
+	value, diags := c.Name.Value(ctx)
+	if diags.HasErrors() {
+		return "", errors.Wrap(diags, "Failed to evaluate context name")
+	}
+
+	if value.IsNull() || !value.Type().Equals(cty.String) || value.AsString() == "" {
+		return "", errors.Errorf("Context name must be a non empty string")
+	}
+
+	return value.AsString(), nil
+}
+
+// MatchOutput returns true if output name matches any of include, or include is
+// empty, and does not match any of exclude. Patterns use path.Match syntax.
+func MatchOutput(name string, include []string, exclude []string) bool {
+	if len(include) > 0 && !matchAny(name, include) {
+		return false
+	}
+
+	return !matchAny(name, exclude)
+}
+
+func matchAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func resolvePaths(dir string, paths []string) []string {
+	result := []string{}
+
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+
+		result = append(result, p)
+	}
+
+	return result
+}
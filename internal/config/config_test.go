@@ -0,0 +1,279 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes content to the configuration file in dir.
+func writeConfig(t *testing.T, dir string, content string) string {
+	t.Helper()
+
+	fn := filepath.Join(dir, FileName)
+	if err := ioutil.WriteFile(fn, []byte(content), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	return fn
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T, dir string, config *Config)
+		wantErr string
+	}{
+		{
+			name:    "empty file",
+			content: ``,
+			check: func(t *testing.T, dir string, config *Config) {
+				if config.Outputs.Secrets != SecretsSensitive {
+					t.Errorf("Secrets = %q, want %q", config.Outputs.Secrets, SecretsSensitive)
+				}
+				if config.Context.HasName() {
+					t.Errorf("HasName() = true, want false without name")
+				}
+				if config.Process.Output != "" || len(config.Process.Inputs) != 0 {
+					t.Errorf("Process = %+v, want no inputs or output", config.Process)
+				}
+			},
+		},
+		{
+			name: "all settings",
+			content: `
+context {
+  name          = "${stack}-outputs"
+  previous_name = "network"
+  labels        = ["team:network"]
+  autoattach    = true
+}
+
+provider {
+  source  = "example.com/spacelift"
+  version = "~> 2.0"
+  merge   = true
+}
+
+outputs {
+  include = ["*"]
+  exclude = ["internal_*"]
+  secrets = "all"
+}
+
+process {
+  inputs         = ["terraform.workspace.tfvars", "/abs/other.tfvars"]
+  output         = "terraform.auto.tfvars"
+  naming         = ["*.tmpl.tfvars=*.auto.tfvars"]
+  source_folders = [".contexts"]
+  ignore_errors  = true
+}
+`,
+			check: func(t *testing.T, dir string, config *Config) {
+				if !config.Context.HasName() || config.Context.PreviousName != "network" || !config.Context.Autoattach {
+					t.Errorf("Context = %+v", config.Context)
+				}
+				if config.Provider.Source != "example.com/spacelift" || config.Provider.Version != "~> 2.0" || !config.Provider.Merge {
+					t.Errorf("Provider = %+v", config.Provider)
+				}
+				if config.Outputs.Secrets != SecretsAll || strings.Join(config.Outputs.Exclude, ",") != "internal_*" {
+					t.Errorf("Outputs = %+v", config.Outputs)
+				}
+
+				// Paths are relative to the configuration file, naming rules are patterns
+				inputs := []string{filepath.Join(dir, "terraform.workspace.tfvars"), "/abs/other.tfvars"}
+				if strings.Join(config.Process.Inputs, ",") != strings.Join(inputs, ",") {
+					t.Errorf("Inputs = %v, want %v", config.Process.Inputs, inputs)
+				}
+				if config.Process.Output != filepath.Join(dir, "terraform.auto.tfvars") {
+					t.Errorf("Output = %s, want relative to %s", config.Process.Output, dir)
+				}
+				if len(config.Process.SourceFolders) != 1 || config.Process.SourceFolders[0] != filepath.Join(dir, ".contexts") {
+					t.Errorf("SourceFolders = %v, want relative to %s", config.Process.SourceFolders, dir)
+				}
+				if strings.Join(config.Process.Naming, ",") != "*.tmpl.tfvars=*.auto.tfvars" || !config.Process.IgnoreErrors {
+					t.Errorf("Process = %+v", config.Process)
+				}
+			},
+		},
+		{name: "invalid secrets", content: `outputs { secrets = "some" }`, wantErr: `Invalid secrets "some"`},
+		{name: "invalid syntax", content: `outputs {`, wantErr: "Failed to parse"},
+		{name: "unknown block", content: `stack {}`, wantErr: "Failed to read"},
+		{name: "unknown attribute", content: `process { outputs = "x" }`, wantErr: "Failed to read"},
+		{name: "wrong type", content: `context { labels = "network" }`, wantErr: "Failed to read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			config, err := Read(writeConfig(t, dir, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Read() error = %v, want %s", err, tt.wantErr)
+				}
+
+				// Commands use defaults from the returned configuration even if it fails
+				if config == nil || config.Outputs == nil || config.Process == nil || config.Path != "" {
+					t.Errorf("Read() with error should return an empty configuration, got %+v", config)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			if config.Path != filepath.Join(dir, FileName) {
+				t.Errorf("Path = %s, want %s", config.Path, filepath.Join(dir, FileName))
+			}
+
+			tt.check(t, dir, config)
+		})
+	}
+
+	if _, err := Read(filepath.Join(t.TempDir(), FileName)); err == nil {
+		t.Errorf("Read() of missing file should fail")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "stacks", "network")
+
+	if err := os.MkdirAll(nested, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(nested)
+	if err != nil {
+		t.Fatalf("Load() without file error = %v", err)
+	}
+
+	// Parents of the temporary folder are not expected to have a configuration file
+	if config.Path != "" || config.Outputs.Secrets != SecretsSensitive {
+		t.Errorf("Load() without file = %+v, want empty configuration", config)
+	}
+
+	fn := writeConfig(t, root, `outputs { secrets = "none" }`)
+
+	config, err = Load(nested)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if config.Path != fn || config.Outputs.Secrets != SecretsNone {
+		t.Errorf("Load() = %s with secrets %s, want %s with secrets none", config.Path, config.Outputs.Secrets, fn)
+	}
+
+	// The nearest file is used
+	nearest := writeConfig(t, nested, `outputs { secrets = "all" }`)
+
+	config, err = Load(nested)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if config.Path != nearest || config.Outputs.Secrets != SecretsAll {
+		t.Errorf("Load() = %s, want %s", config.Path, nearest)
+	}
+
+	writeConfig(t, nested, `outputs {`)
+
+	if _, err := Load(nested); err == nil || !strings.Contains(err.Error(), "Failed to parse") {
+		t.Errorf("Load() of invalid file error = %v, want Failed to parse", err)
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	dir := filepath.FromSlash("/repo/config")
+
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{[]string{"a.tfvars"}, []string{filepath.Join(dir, "a.tfvars")}},
+		{[]string{"../contexts", "./b"}, []string{filepath.FromSlash("/repo/contexts"), filepath.Join(dir, "b")}},
+		{[]string{filepath.FromSlash("/abs/c")}, []string{filepath.FromSlash("/abs/c")}},
+		{[]string{"*.workspace.tfvars"}, []string{filepath.Join(dir, "*.workspace.tfvars")}},
+	}
+
+	for _, tt := range tests {
+		got := resolvePaths(dir, tt.paths)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") || got == nil {
+			t.Errorf("resolvePaths(%v) = %v, want %v", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestContextName(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		stack   string
+		want    string
+		wantErr string
+	}{
+		{name: "static name", content: `context { name = "network" }`, stack: "network-dev", want: "network"},
+		{name: "template", content: `context { name = "${stack}-outputs" }`, stack: "network-dev", want: "network-dev-outputs"},
+		{name: "function", content: `context { name = replace(stack, "-dev", "") }`, stack: "network-dev", wantErr: "Failed to evaluate context name"},
+		{name: "conditional", content: `context { name = stack == null ? "local" : stack }`, want: "local"},
+		{name: "null stack in template", content: `context { name = "${stack}-outputs" }`, wantErr: "Failed to evaluate context name"},
+		{name: "empty name", content: `context { name = "" }`, wantErr: "Context name must be a non empty string"},
+		{name: "not a string", content: `context { name = ["network"] }`, wantErr: "Context name must be a non empty string"},
+		{name: "unknown variable", content: `context { name = var.name }`, wantErr: "Failed to evaluate context name"},
+		{name: "not set", content: ``, stack: "network-dev", wantErr: "Context name must be a non empty string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := Read(writeConfig(t, t.TempDir(), tt.content))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			got, err := config.Context.ContextName(tt.stack)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ContextName(%q) error = %v, want %s", tt.stack, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ContextName(%q) error = %v", tt.stack, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ContextName(%q) = %s, want %s", tt.stack, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    bool
+	}{
+		{"vnet_id", nil, nil, true},
+		{"vnet_id", []string{"vnet_*"}, nil, true},
+		{"subnet_id", []string{"vnet_*"}, nil, false},
+		{"internal_key", nil, []string{"internal_*"}, false},
+		{"internal_key", []string{"*"}, []string{"internal_*"}, false},
+		{"vnet_id", []string{"subnet_*", "vnet_id"}, []string{"internal_*"}, true},
+		{"vnet_id", []string{"[invalid"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchOutput(tt.name, tt.include, tt.exclude); got != tt.want {
+			t.Errorf("MatchOutput(%q, %v, %v) = %v, want %v", tt.name, tt.include, tt.exclude, got, tt.want)
+		}
+	}
+}
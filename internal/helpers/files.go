@@ -10,11 +10,63 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/pkg/errors"
 )
 
 func ReadFiles(fn string) ([]*hclwrite.File, error) {
+	names, err := ListFiles(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*hclwrite.File{}
+	for _, name := range names {
+		file, err := processFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if file == nil {
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// ParseFiles parses the same files as ReadFiles, but as native syntax files so
+// expressions can be inspected.
+func ParseFiles(fn string) ([]*hcl.File, error) {
+	names, err := ListFiles(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*hcl.File{}
+	for _, name := range names {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read file %s", name)
+		}
+
+		log.Debugf("Parsing hcl file %s", name)
+		file, diags := hclsyntax.ParseConfig(src, name, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			logDiags(diags)
+			return nil, diags
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// ListFiles returns the tf files in fn, or fn itself if it is a tf file.
+func ListFiles(fn string) ([]string, error) {
 	if fn == "" {
 		fn = "."
 	}
@@ -26,20 +78,33 @@ func ReadFiles(fn string) ([]*hclwrite.File, error) {
 		return nil, errors.Wrapf(err, "Failed to stat %q", fn)
 	}
 
-	if info.IsDir() {
-		if info.Name() != "." && info.Name() != ".." && strings.HasPrefix(info.Name(), ".") {
-			return nil, nil
+	if !info.IsDir() {
+		if isTerraformFile(fn, info) {
+			return []string{fn}, nil
 		}
 
-		return processDir(fn)
-	} else {
-		f, err := ReadFile(fn)
-		if f == nil {
-			return []*hclwrite.File{}, err
-		}
+		return []string{}, nil
+	}
+
+	if info.Name() != "." && info.Name() != ".." && strings.HasPrefix(info.Name(), ".") {
+		return nil, nil
+	}
 
-		return []*hclwrite.File{f}, err
+	entries, err := ioutil.ReadDir(fn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read directory %s", fn)
 	}
+
+	files := []string{}
+	for _, entry := range entries {
+		name := filepath.Join(fn, entry.Name())
+
+		if isTerraformFile(name, entry) {
+			files = append(files, name)
+		}
+	}
+
+	return files, nil
 }
 
 func ReadFile(fn string) (*hclwrite.File, error) {
@@ -50,42 +115,25 @@ func ReadFile(fn string) (*hclwrite.File, error) {
 		return nil, errors.Wrapf(err, "Failed to stat %s", fn)
 	}
 
-	if info.IsDir() {
-		log.Debugf("Skipping %s: it is a directory", fn)
-		return nil, nil
-	}
-
-	if !info.Mode().IsRegular() {
-		log.Debugf("Skipping %s: not a regular file or directory", fn)
-		return nil, nil
-	}
-	if !strings.HasSuffix(fn, ".tf") {
+	if !isTerraformFile(fn, info) {
 		return nil, nil
 	}
 
 	return processFile(fn)
 }
 
-func processDir(fn string) ([]*hclwrite.File, error) {
-	entries, err := ioutil.ReadDir(fn)
-	if err != nil {
-		return nil, errors.Wrapf(err, "Failed to read directory %s", fn)
+func isTerraformFile(fn string, info os.FileInfo) bool {
+	if info.IsDir() {
+		log.Debugf("Skipping %s: it is a directory", fn)
+		return false
 	}
 
-	files := []*hclwrite.File{}
-	for _, entry := range entries {
-		file, err := ReadFile(filepath.Join(fn, entry.Name()))
-		if err != nil {
-			return nil, err
-		}
-		if file == nil {
-			continue
-		}
-
-		files = append(files, file)
+	if !info.Mode().IsRegular() {
+		log.Debugf("Skipping %s: not a regular file or directory", fn)
+		return false
 	}
 
-	return files, nil
+	return strings.HasSuffix(fn, ".tf")
 }
 
 func processFile(fn string) (*hclwrite.File, error) {
@@ -103,15 +151,19 @@ func processFile(fn string) (*hclwrite.File, error) {
 	log.Debugf("Parsing hcl file %s", fn)
 	f, diags := hclwrite.ParseConfig(src, fn, hcl.Pos{Line: 1, Column: 1})
 	if diags.HasErrors() {
-		for _, diag := range diags {
-			if diag.Subject != nil {
-				log.Printf("[%s:%d] %s: %s", diag.Subject.Filename, diag.Subject.Start.Line, diag.Summary, diag.Detail)
-			} else {
-				log.Printf("%s: %s", diag.Summary, diag.Detail)
-			}
-		}
+		logDiags(diags)
 		return nil, diags
 	}
 
 	return f, err
 }
+
+func logDiags(diags hcl.Diagnostics) {
+	for _, diag := range diags {
+		if diag.Subject != nil {
+			log.Printf("[%s:%d] %s: %s", diag.Subject.Filename, diag.Subject.Start.Line, diag.Summary, diag.Detail)
+		} else {
+			log.Printf("%s: %s", diag.Summary, diag.Detail)
+		}
+	}
+}
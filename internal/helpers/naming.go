@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// NamingRule maps an input file name to an output file name. Both sides are
+// patterns containing a single `*` wildcard, for example
+// `*.workspace.tfvars=*.auto.tfvars`.
+type NamingRule struct {
+	fromPrefix string
+	fromSuffix string
+	toPrefix   string
+	toSuffix   string
+}
+
+// ParseNamingRule parses a rule written as `from=to`.
+func ParseNamingRule(rule string) (NamingRule, error) {
+	parts := strings.Split(rule, "=")
+	if len(parts) != 2 {
+		return NamingRule{}, errors.Errorf("Invalid naming rule %q, expected format from=to", rule)
+	}
+
+	from := strings.Split(parts[0], "*")
+	to := strings.Split(parts[1], "*")
+
+	if len(from) != 2 || len(to) != 2 {
+		return NamingRule{}, errors.Errorf("Invalid naming rule %q, both sides must contain exactly one *", rule)
+	}
+
+	if parts[0] == parts[1] {
+		return NamingRule{}, errors.Errorf("Invalid naming rule %q, output would overwrite input", rule)
+	}
+
+	return NamingRule{
+		fromPrefix: from[0],
+		fromSuffix: from[1],
+		toPrefix:   to[0],
+		toSuffix:   to[1],
+	}, nil
+}
+
+// Match returns the output file name for fn, placed in the same directory, and
+// whether the rule applies to fn at all.
+func (r NamingRule) Match(fn string) (string, bool) {
+	dir, base := filepath.Split(fn)
+
+	if len(base) < len(r.fromPrefix)+len(r.fromSuffix) {
+		return "", false
+	}
+
+	if !strings.HasPrefix(base, r.fromPrefix) || !strings.HasSuffix(base, r.fromSuffix) {
+		return "", false
+	}
+
+	stem := base[len(r.fromPrefix) : len(base)-len(r.fromSuffix)]
+
+	return filepath.Join(dir, r.toPrefix+stem+r.toSuffix), true
+}
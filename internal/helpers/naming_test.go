@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseNamingRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr bool
+	}{
+		{"*.workspace.tfvars=*.auto.tfvars", false},
+		{"env-*.tfvars=*.auto.tfvars", false},
+		{"*.tfvars", true},
+		{"*.tfvars=*.auto.tfvars=x", true},
+		{"workspace.tfvars=*.auto.tfvars", true},
+		{"*.workspace.tfvars=auto.tfvars", true},
+		{"*.*.tfvars=*.auto.tfvars", true},
+		{"*.tfvars=*.tfvars", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := ParseNamingRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseNamingRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNamingRuleMatch(t *testing.T) {
+	tests := []struct {
+		rule   string
+		fn     string
+		want   string
+		wantOk bool
+	}{
+		{"*.workspace.tfvars=*.auto.tfvars", "terraform.workspace.tfvars", "terraform.auto.tfvars", true},
+		{"*.workspace.tfvars=*.auto.tfvars", filepath.Join("env", "dev.workspace.tfvars"), filepath.Join("env", "dev.auto.tfvars"), true},
+		{"*.workspace.tfvars=*.auto.tfvars", ".workspace.tfvars", ".auto.tfvars", true},
+		{"*.workspace.tfvars=*.auto.tfvars", "terraform.tfvars", "", false},
+		{"*.workspace.tfvars=*.auto.tfvars", "terraform.workspace.tfvars.json", "", false},
+		{"*.workspace.tfvars.json=*.auto.tfvars.json", "dev.workspace.tfvars.json", "dev.auto.tfvars.json", true},
+		{"env-*.tfvars=*.auto.tfvars", "env-dev.tfvars", "dev.auto.tfvars", true},
+		{"env-*.tfvars=*.auto.tfvars", "dev.tfvars", "", false},
+		// Prefix and suffix overlap in the name, so there is no room for the wildcard
+		{"a*a=*.auto.tfvars", "a", "", false},
+		{"a*a=*.auto.tfvars", "aa", ".auto.tfvars", true},
+		{"ab*ba=*.auto.tfvars", "aba", "", false},
+		// Only the base name is matched, not the directory
+		{"env-*.tfvars=*.auto.tfvars", filepath.Join("env-x", "dev.tfvars"), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+" "+tt.fn, func(t *testing.T) {
+			rule, err := ParseNamingRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseNamingRule(%q) error = %v", tt.rule, err)
+			}
+
+			got, ok := rule.Match(tt.fn)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("Match(%q) = %q, %v, want %q, %v", tt.fn, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
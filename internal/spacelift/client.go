@@ -0,0 +1,133 @@
+package spacelift
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pkg/errors"
+)
+
+// Client is a minimal client for the Spacelift GraphQL API, only supporting the
+// operations used by spacectx.
+type Client struct {
+	endpoint string
+	token    string
+	http     *http.Client
+}
+
+type request struct {
+	OperationName string                 `json:"operationName"`
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+const (
+	graphqlPath = "/graphql"
+
+	getTokenQuery = `mutation GetToken($id: ID!, $secret: String!) {
+  apiKeyUser(id: $id, secret: $secret) { jwt }
+}`
+)
+
+// NewClient returns a client for the account at endpoint, like
+// https://example.app.spacelift.io, authenticating with token.
+func NewClient(endpoint string, token string) *Client {
+	return &Client{
+		endpoint: strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), graphqlPath) + graphqlPath,
+		token:    token,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewClientWithAPIKey returns a client authenticated by exchanging an API key for
+// a token.
+func NewClientWithAPIKey(endpoint string, keyID string, keySecret string) (*Client, error) {
+	client := NewClient(endpoint, "")
+
+	result := struct {
+		APIKeyUser *struct {
+			JWT string `json:"jwt"`
+		} `json:"apiKeyUser"`
+	}{}
+
+	err := client.do("GetToken", getTokenQuery, map[string]interface{}{"id": keyID, "secret": keySecret}, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get token for API key")
+	}
+
+	if result.APIKeyUser == nil || result.APIKeyUser.JWT == "" {
+		return nil, errors.Errorf("Failed to get token for API key, check key id and secret")
+	}
+
+	client.token = result.APIKeyUser.JWT
+
+	return client, nil
+}
+
+func (c *Client) do(operation string, query string, variables map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(&request{OperationName: operation, Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	log.Debugf("Sending %s to %s", operation, c.endpoint)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to send %s", operation)
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read response of %s", operation)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s failed with status %s", operation, resp.Status)
+	}
+
+	res := &response{}
+	if err := json.Unmarshal(content, res); err != nil {
+		return errors.Wrapf(err, "Failed to parse response of %s", operation)
+	}
+
+	if len(res.Errors) > 0 {
+		messages := []string{}
+		for _, e := range res.Errors {
+			messages = append(messages, e.Message)
+		}
+
+		return errors.Errorf("%s failed: %s", operation, strings.Join(messages, ", "))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(res.Data, result)
+}
@@ -0,0 +1,152 @@
+package spacelift_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/2ttech/spacectx/internal/spacelift"
+	"github.com/2ttech/spacectx/internal/spacelift/fake"
+)
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"network", "network"},
+		{"Network-Dev", "network-dev"},
+		{"network.dev", "network-dev"},
+		{"  network  dev  ", "network-dev"},
+		{"_network_", "network"},
+	}
+
+	for _, tt := range tests {
+		if got := spacelift.Slug(tt.name); got != tt.want {
+			t.Errorf("Slug(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	server := fake.NewServer("token")
+	defer server.Close()
+
+	client := spacelift.NewClient(server.URL, "token")
+
+	ctx, err := client.Context("network")
+	if err != nil {
+		t.Fatalf("Context() error = %v", err)
+	}
+
+	if ctx != nil {
+		t.Fatalf("Context() = %v, want nil for missing context", ctx)
+	}
+
+	created, err := client.CreateContext("Network Dev", "description")
+	if err != nil {
+		t.Fatalf("CreateContext() error = %v", err)
+	}
+
+	if created.ID != "network-dev" || created.Name != "Network Dev" {
+		t.Errorf("CreateContext() = %s/%s, want network-dev/Network Dev", created.ID, created.Name)
+	}
+
+	if _, err := client.CreateContext("Network Dev", "description"); err == nil {
+		t.Errorf("CreateContext() of existing context should fail")
+	}
+
+	for _, input := range []*spacelift.ConfigInput{
+		{ID: "ctx-public.json", Type: spacelift.ConfigTypeFileMount, Value: "cHVibGlj"},
+		{ID: "ctx-secret.json", Type: spacelift.ConfigTypeFileMount, Value: "c2VjcmV0", WriteOnly: true},
+	} {
+		if err := client.AddConfig(created.ID, input); err != nil {
+			t.Fatalf("AddConfig(%s) error = %v", input.ID, err)
+		}
+	}
+
+	ctx, err = client.Context(created.ID)
+	if err != nil {
+		t.Fatalf("Context() error = %v", err)
+	}
+
+	if len(ctx.Config) != 2 {
+		t.Fatalf("Context() has %d config elements, want 2", len(ctx.Config))
+	}
+
+	for _, element := range ctx.Config {
+		switch element.ID {
+		case "ctx-public.json":
+			if element.Value == nil || *element.Value != "cHVibGlj" {
+				t.Errorf("Value of %s = %v, want cHVibGlj", element.ID, element.Value)
+			}
+		case "ctx-secret.json":
+			if !element.WriteOnly || element.Value != nil {
+				t.Errorf("Value of write only %s should be hidden, got %v", element.ID, element.Value)
+			}
+		default:
+			t.Errorf("Unexpected config element %s", element.ID)
+		}
+	}
+
+	if err := client.DeleteConfig(created.ID, "ctx-secret.json"); err != nil {
+		t.Fatalf("DeleteConfig() error = %v", err)
+	}
+
+	if err := client.DeleteConfig(created.ID, "ctx-secret.json"); err == nil {
+		t.Errorf("DeleteConfig() of missing element should fail")
+	}
+
+	if got := server.Context(created.ID); len(got.Config) != 1 || got.Config[0].ID != "ctx-public.json" {
+		t.Errorf("Context after delete = %v, want only ctx-public.json", got.Config)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := fake.NewServer("token")
+	defer server.Close()
+
+	_, err := spacelift.NewClient(server.URL, "wrong").Context("network")
+	if err == nil || !strings.Contains(err.Error(), "GetContext failed: unauthorized") {
+		t.Errorf("Context() with bad token error = %v, want GetContext failed: unauthorized", err)
+	}
+
+	err = spacelift.NewClient(server.URL, "token").AddConfig("missing", &spacelift.ConfigInput{ID: "file"})
+	if err == nil || !strings.Contains(err.Error(), "context missing not found") {
+		t.Errorf("AddConfig() to missing context error = %v, want context missing not found", err)
+	}
+
+	_, err = spacelift.NewClient(server.URL+"/other", "token").Context("network")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Context() on wrong path error = %v, want status 404", err)
+	}
+}
+
+func TestNewClientWithAPIKey(t *testing.T) {
+	server := fake.NewServer("jwt")
+	defer server.Close()
+
+	server.APIKeys["key"] = "secret"
+	server.AddContext(&spacelift.Context{ID: "network", Name: "network"})
+
+	client, err := spacelift.NewClientWithAPIKey(server.URL+"/", "key", "secret")
+	if err != nil {
+		t.Fatalf("NewClientWithAPIKey() error = %v", err)
+	}
+
+	ctx, err := client.Context("network")
+	if err != nil {
+		t.Fatalf("Context() with exchanged token error = %v", err)
+	}
+
+	if ctx == nil || ctx.ID != "network" {
+		t.Errorf("Context() = %v, want network", ctx)
+	}
+
+	if _, err := spacelift.NewClientWithAPIKey(server.URL, "key", "wrong"); err == nil {
+		t.Errorf("NewClientWithAPIKey() with wrong secret should fail")
+	}
+
+	if _, err := spacelift.NewClientWithAPIKey(server.URL, "other", "secret"); err == nil {
+		t.Errorf("NewClientWithAPIKey() with unknown key should fail")
+	}
+}
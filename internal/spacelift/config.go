@@ -0,0 +1,188 @@
+package spacelift
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the path of the spacelift config file relative to repository root.
+const ConfigFile = ".spacelift/config.yml"
+
+// Config is the content of .spacelift/config.yml. Only settings used by spacectx
+// are read.
+type Config struct {
+	Version       string                    `yaml:"version"`
+	StackDefaults *StackSettings            `yaml:"stack_defaults"`
+	Stacks        map[string]*StackSettings `yaml:"stacks"`
+
+	// Root is the repository root the config file was found in
+	Root string `yaml:"-"`
+}
+
+// StackSettings are the settings of a stack, or the defaults for all stacks.
+type StackSettings struct {
+	ProjectRoot string   `yaml:"project_root"`
+	Labels      []string `yaml:"labels"`
+	BeforeInit  []string `yaml:"before_init"`
+}
+
+// Stack is a stack in the config file with defaults applied.
+type Stack struct {
+	ID          string
+	ProjectRoot string
+	Labels      []string
+}
+
+// FindConfig searches for the config file in dir and its parents. It returns nil
+// if no config file is found.
+func FindConfig(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		fn := filepath.Join(dir, filepath.FromSlash(ConfigFile))
+
+		if _, err := os.Stat(fn); err == nil {
+			config, err := ReadConfig(fn)
+			if err != nil {
+				return nil, err
+			}
+
+			config.Root = dir
+			return config, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+
+		dir = parent
+	}
+}
+
+// ReadConfig reads the config file fn.
+func ReadConfig(fn string) (*Config, error) {
+	src, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read file %v", fn)
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(src, config); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse %s", fn)
+	}
+
+	return config, nil
+}
+
+// Stack returns stack id with defaults applied, or nil if it is not defined.
+func (c *Config) Stack(id string) *Stack {
+	settings, ok := c.Stacks[id]
+	if !ok {
+		return nil
+	}
+
+	stack := &Stack{ID: id}
+
+	for _, s := range []*StackSettings{c.StackDefaults, settings} {
+		if s == nil {
+			continue
+		}
+
+		if s.ProjectRoot != "" {
+			stack.ProjectRoot = s.ProjectRoot
+		}
+
+		for _, label := range s.Labels {
+			if !containsString(stack.Labels, label) {
+				stack.Labels = append(stack.Labels, label)
+			}
+		}
+	}
+
+	stack.ProjectRoot = cleanProjectRoot(stack.ProjectRoot)
+
+	return stack
+}
+
+// StackForDir returns the stack with dir as project root, or nil if there is none.
+// It fails if several stacks use the same project root.
+func (c *Config) StackForDir(dir string) (*Stack, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := filepath.Rel(c.Root, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, nil
+	}
+
+	rel = cleanProjectRoot(filepath.ToSlash(rel))
+
+	ids := []string{}
+	for id := range c.Stacks {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	var found *Stack
+
+	for _, id := range ids {
+		stack := c.Stack(id)
+		if stack.ProjectRoot != rel {
+			continue
+		}
+
+		if found != nil {
+			return nil, errors.Errorf("Both stack %s and %s have project root %s, set name explicitly", found.ID, stack.ID, rel)
+		}
+
+		found = stack
+	}
+
+	return found, nil
+}
+
+func cleanProjectRoot(root string) string {
+	if root == "" {
+		return "."
+	}
+
+	return strings.TrimSuffix(filepath.ToSlash(filepath.Clean(root)), "/")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StacksWithLabel returns the ids of stacks having label, sorted.
+func (c *Config) StacksWithLabel(label string) []string {
+	ids := []string{}
+
+	for id := range c.Stacks {
+		if containsString(c.Stack(id).Labels, label) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
@@ -0,0 +1,104 @@
+package spacelift
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Context is a spacelift context with its configuration elements.
+type Context struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description *string          `json:"description"`
+	Config      []*ConfigElement `json:"config"`
+}
+
+// ConfigElement is an environment variable or mounted file in a context. Value is
+// not returned for write only elements.
+type ConfigElement struct {
+	ID        string  `json:"id"`
+	Type      string  `json:"type"`
+	Value     *string `json:"value"`
+	WriteOnly bool    `json:"writeOnly"`
+}
+
+// ConfigInput is a configuration element to add to a context. The value of
+// mounted files is base64 encoded.
+type ConfigInput struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	WriteOnly bool   `json:"writeOnly"`
+}
+
+const (
+	// ConfigTypeFileMount is the type of mounted files
+	ConfigTypeFileMount = "FILE_MOUNT"
+
+	getContextQuery = `query GetContext($id: ID!) {
+  context(id: $id) { id name description config { id type value writeOnly } }
+}`
+
+	createContextQuery = `mutation CreateContext($name: String!, $description: String) {
+  contextCreate(name: $name, description: $description) { id name description }
+}`
+
+	addContextConfigQuery = `mutation AddContextConfig($context: ID!, $config: ConfigInput!) {
+  contextConfigAdd(context: $context, config: $config) { id }
+}`
+
+	deleteContextConfigQuery = `mutation DeleteContextConfig($context: ID!, $id: ID!) {
+  contextConfigDelete(context: $context, id: $id) { id }
+}`
+)
+
+var (
+	invalidSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Slug returns the id spacelift gives a context named name.
+func Slug(name string) string {
+	return strings.Trim(invalidSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// Context returns the context with id, or nil if it does not exist.
+func (c *Client) Context(id string) (*Context, error) {
+	result := struct {
+		Context *Context `json:"context"`
+	}{}
+
+	if err := c.do("GetContext", getContextQuery, map[string]interface{}{"id": id}, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Context, nil
+}
+
+// CreateContext creates a context named name.
+func (c *Client) CreateContext(name string, description string) (*Context, error) {
+	result := struct {
+		Context *Context `json:"contextCreate"`
+	}{}
+
+	variables := map[string]interface{}{"name": name, "description": description}
+
+	if err := c.do("CreateContext", createContextQuery, variables, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Context, nil
+}
+
+// AddConfig adds or replaces a configuration element in context.
+func (c *Client) AddConfig(context string, config *ConfigInput) error {
+	variables := map[string]interface{}{"context": context, "config": config}
+
+	return c.do("AddContextConfig", addContextConfigQuery, variables, nil)
+}
+
+// DeleteConfig deletes configuration element id from context.
+func (c *Client) DeleteConfig(context string, id string) error {
+	variables := map[string]interface{}{"context": context, "id": id}
+
+	return c.do("DeleteContextConfig", deleteContextConfigQuery, variables, nil)
+}
@@ -0,0 +1,262 @@
+// Package fake provides an in-memory stand-in for the Spacelift GraphQL API, so
+// the spacelift client can be used and tested without network access.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/2ttech/spacectx/internal/spacelift"
+)
+
+// Server serves the operations used by the spacelift client. Operations are
+// dispatched on operationName, so queries are not parsed.
+type Server struct {
+	*httptest.Server
+
+	// Token is the bearer token required by all operations except GetToken
+	Token string
+	// APIKeys maps API key ids to secrets accepted by GetToken
+	APIKeys map[string]string
+
+	mu       sync.Mutex
+	contexts map[string]*spacelift.Context
+}
+
+type request struct {
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+}
+
+// NewServer starts a server accepting token. Close it when done.
+func NewServer(token string) *Server {
+	s := &Server{
+		Token:    token,
+		APIKeys:  map[string]string{},
+		contexts: map[string]*spacelift.Context{},
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// AddContext adds or replaces a context.
+func (s *Server) AddContext(ctx *spacelift.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contexts[ctx.ID] = ctx
+}
+
+// Context returns a copy of context with id, or nil if it does not exist.
+func (s *Server) Context(id string) *spacelift.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, ok := s.contexts[id]
+	if !ok {
+		return nil
+	}
+
+	return copyContext(ctx, false)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+		http.NotFound(w, r)
+		return
+	}
+
+	req := &request{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.OperationName != "GetToken" && r.Header.Get("Authorization") != fmt.Sprintf("Bearer %s", s.Token) {
+		writeError(w, "unauthorized")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var data interface{}
+	var err error
+
+	switch req.OperationName {
+	case "GetToken":
+		data, err = s.getToken(req.Variables)
+	case "GetContext":
+		data, err = s.getContext(req.Variables)
+	case "CreateContext":
+		data, err = s.createContext(req.Variables)
+	case "AddContextConfig":
+		data, err = s.addContextConfig(req.Variables)
+	case "DeleteContextConfig":
+		data, err = s.deleteContextConfig(req.Variables)
+	default:
+		err = fmt.Errorf("unsupported operation %q", req.OperationName)
+	}
+
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func (s *Server) getToken(raw json.RawMessage) (interface{}, error) {
+	vars := struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}{}
+	if err := json.Unmarshal(raw, &vars); err != nil {
+		return nil, err
+	}
+
+	if secret, ok := s.APIKeys[vars.ID]; !ok || secret != vars.Secret {
+		return map[string]interface{}{"apiKeyUser": nil}, nil
+	}
+
+	return map[string]interface{}{"apiKeyUser": map[string]string{"jwt": s.Token}}, nil
+}
+
+func (s *Server) getContext(raw json.RawMessage) (interface{}, error) {
+	vars := struct {
+		ID string `json:"id"`
+	}{}
+	if err := json.Unmarshal(raw, &vars); err != nil {
+		return nil, err
+	}
+
+	ctx, ok := s.contexts[vars.ID]
+	if !ok {
+		return map[string]interface{}{"context": nil}, nil
+	}
+
+	return map[string]interface{}{"context": copyContext(ctx, true)}, nil
+}
+
+func (s *Server) createContext(raw json.RawMessage) (interface{}, error) {
+	vars := struct {
+		Name        string  `json:"name"`
+		Description *string `json:"description"`
+	}{}
+	if err := json.Unmarshal(raw, &vars); err != nil {
+		return nil, err
+	}
+
+	id := spacelift.Slug(vars.Name)
+	if _, exists := s.contexts[id]; exists {
+		return nil, fmt.Errorf("context %s already exists", id)
+	}
+
+	ctx := &spacelift.Context{ID: id, Name: vars.Name, Description: vars.Description}
+	s.contexts[id] = ctx
+
+	return map[string]interface{}{"contextCreate": copyContext(ctx, true)}, nil
+}
+
+func (s *Server) addContextConfig(raw json.RawMessage) (interface{}, error) {
+	vars := struct {
+		Context string                 `json:"context"`
+		Config  *spacelift.ConfigInput `json:"config"`
+	}{}
+	if err := json.Unmarshal(raw, &vars); err != nil {
+		return nil, err
+	}
+
+	ctx, ok := s.contexts[vars.Context]
+	if !ok {
+		return nil, fmt.Errorf("context %s not found", vars.Context)
+	}
+
+	if vars.Config == nil || vars.Config.ID == "" {
+		return nil, fmt.Errorf("config id is required")
+	}
+
+	value := vars.Config.Value
+	element := &spacelift.ConfigElement{
+		ID:        vars.Config.ID,
+		Type:      vars.Config.Type,
+		Value:     &value,
+		WriteOnly: vars.Config.WriteOnly,
+	}
+
+	config := []*spacelift.ConfigElement{element}
+	for _, existing := range ctx.Config {
+		if existing.ID != element.ID {
+			config = append(config, existing)
+		}
+	}
+
+	sort.Slice(config, func(i, j int) bool { return config[i].ID < config[j].ID })
+	ctx.Config = config
+
+	return map[string]interface{}{"contextConfigAdd": map[string]string{"id": element.ID}}, nil
+}
+
+func (s *Server) deleteContextConfig(raw json.RawMessage) (interface{}, error) {
+	vars := struct {
+		Context string `json:"context"`
+		ID      string `json:"id"`
+	}{}
+	if err := json.Unmarshal(raw, &vars); err != nil {
+		return nil, err
+	}
+
+	ctx, ok := s.contexts[vars.Context]
+	if !ok {
+		return nil, fmt.Errorf("context %s not found", vars.Context)
+	}
+
+	config := []*spacelift.ConfigElement{}
+	for _, existing := range ctx.Config {
+		if existing.ID != vars.ID {
+			config = append(config, existing)
+		}
+	}
+
+	if len(config) == len(ctx.Config) {
+		return nil, fmt.Errorf("config %s not found", vars.ID)
+	}
+
+	ctx.Config = config
+
+	return map[string]interface{}{"contextConfigDelete": map[string]string{"id": vars.ID}}, nil
+}
+
+// copyContext copies ctx, hiding values of write only elements if hide is set
+// the same way the API does.
+func copyContext(ctx *spacelift.Context, hide bool) *spacelift.Context {
+	result := *ctx
+	result.Config = []*spacelift.ConfigElement{}
+
+	for _, element := range ctx.Config {
+		e := *element
+		if hide && e.WriteOnly {
+			e.Value = nil
+		}
+
+		result.Config = append(result.Config, &e)
+	}
+
+	return &result
+}
+
+func writeError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":   nil,
+		"errors": []map[string]string{{"message": strings.TrimSpace(message)}},
+	})
+}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/2ttech/spacectx/cmd"
@@ -13,9 +14,16 @@ import (
 
 func main() {
 	log.SetFormatter(&log.TextFormatter{ForceColors: true})
-	log.SetOutput(colorable.NewColorableStdout())
+	// Stdout is reserved for command results, which are often piped or evaluated
+	log.SetOutput(colorable.NewColorableStderr())
 
 	if err := cmd.NewRootCmd().Execute(); err != nil {
+		// Executed commands report their own errors, only their exit code is kept
+		var exitErr *cmd.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		log.Error(err)
 		os.Exit(1)
 	}